@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/davecheney/pub/models"
+	"gorm.io/gorm"
+)
+
+type AddAccountKeyCmd struct {
+	Name   string `required:"" help:"name of the account"`
+	Domain string `required:"" help:"domain of the account"`
+}
+
+func (a *AddAccountKeyCmd) Run(ctx *Context) error {
+	db, err := gorm.Open(ctx.Dialector, &ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	actor, err := models.NewActors(db).Find(a.Name, a.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to find actor: %w", err)
+	}
+	accounts := models.NewAccounts(db)
+	account, err := accounts.AccountForActor(actor)
+	if err != nil {
+		return fmt.Errorf("failed to find account: %w", err)
+	}
+	return accounts.AddEd25519Key(account)
+}
@@ -1,22 +1,241 @@
 package activities
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/davecheney/pub/internal/snowflake"
 	"github.com/davecheney/pub/models"
 )
 
 const (
-	FOLLOW = "Follow"
-	LIKE   = "Like"
-	UNDO   = "Undo"
+	ACCEPT   = "Accept"
+	ADD      = "Add"
+	ANNOUNCE = "Announce"
+	BLOCK    = "Block"
+	CREATE   = "Create"
+	DELETE   = "Delete"
+	FLAG     = "Flag"
+	FOLLOW   = "Follow"
+	LIKE     = "Like"
+	MOVE     = "Move"
+	REJECT   = "Reject"
+	REMOVE   = "Remove"
+	UNDO     = "Undo"
+	UPDATE   = "Update"
 )
 
-func Follow(actor, object *models.Actor) map[string]any {
+// Create returns a Create activity wrapping the object, addressed to the same
+// recipients as the object.
+func Create(actor *models.Actor, object map[string]any) map[string]any {
+	return map[string]any{
+		"@context":  "https://www.w3.org/ns/activitystreams",
+		"id":        fmt.Sprintf("%s/activity", object["id"]),
+		"type":      CREATE,
+		"actor":     actor.URI(),
+		"published": object["published"],
+		"to":        object["to"],
+		"cc":        object["cc"],
+		"object":    object,
+	}
+}
+
+// Delete returns a Delete activity for the tombstone of a deleted object.
+func Delete(actor *models.Actor, tombstone map[string]any) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#delete", tombstone["id"]),
+		"type":     DELETE,
+		"actor":    actor.URI(),
+		"to":       []any{"https://www.w3.org/ns/activitystreams#Public"},
+		"object":   tombstone,
+	}
+}
+
+// Announce returns an Announce activity of the status by the actor, addressed
+// to the public, the status' author, and the actor's followers.
+func Announce(actor *models.Actor, status *models.Status) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       models.ReblogURI(actor, status),
+		"type":     ANNOUNCE,
+		"actor":    actor.URI(),
+		"to":       []any{"https://www.w3.org/ns/activitystreams#Public"},
+		"cc":       []any{status.Actor.URI(), actor.URI() + "/followers"},
+		"object":   status.URI(),
+	}
+}
+
+// Add returns an Add activity of the status to the actor's featured collection,
+// addressed to the actor's followers.
+func Add(actor *models.Actor, status *models.Status) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#adds/pins/%d", actor.URI(), snowflake.Now()),
+		"type":     ADD,
+		"actor":    actor.URI(),
+		"to":       []any{actor.URI() + "/followers"},
+		"object":   status.URI(),
+		"target":   actor.URI() + "/collections/featured",
+	}
+}
+
+// Remove returns a Remove activity of the status from the actor's featured collection,
+// addressed to the actor's followers.
+func Remove(actor *models.Actor, status *models.Status) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#removes/pins/%d", actor.URI(), snowflake.Now()),
+		"type":     REMOVE,
+		"actor":    actor.URI(),
+		"to":       []any{actor.URI() + "/followers"},
+		"object":   status.URI(),
+		"target":   actor.URI() + "/collections/featured",
+	}
+}
+
+// Update returns an Update activity wrapping the object. If the object
+// has an @context, it is moved to the activity. The activity has the same
+// addressing as the object, or is public if the object has none.
+func Update(actor *models.Actor, object map[string]any) map[string]any {
+	context, ok := object["@context"]
+	if !ok {
+		context = "https://www.w3.org/ns/activitystreams"
+	}
+	obj := make(map[string]any, len(object))
+	for k, v := range object {
+		if k != "@context" {
+			obj[k] = v
+		}
+	}
+	update := map[string]any{
+		"@context": context,
+		"id":       fmt.Sprintf("%s#updates/%d", obj["id"], snowflake.Now()),
+		"type":     UPDATE,
+		"actor":    actor.URI(),
+		"to":       []any{"https://www.w3.org/ns/activitystreams#Public"},
+		"object":   obj,
+	}
+	if to, ok := obj["to"]; ok {
+		update["to"] = to
+		update["cc"] = obj["cc"]
+	}
+	return update
+}
+
+// Move returns a Move activity announcing the actor has moved to the target.
+func Move(actor *models.Actor, target string) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#moves/%d", actor.URI(), snowflake.Now()),
+		"type":     MOVE,
+		"actor":    actor.URI(),
+		"object":   actor.URI(),
+		"target":   target,
+	}
+}
+
+// Block returns a Block activity of the object by the actor.
+func Block(actor, object *models.Actor) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#blocks/%d", actor.URI(), snowflake.Now()),
+		"type":     BLOCK,
+		"actor":    actor.URI(),
+		"object":   object.URI(),
+	}
+}
+
+// Flag returns a Flag activity of the report by the actor, usually the instance's actor,
+// so the reporter is not revealed. The object is the reported actor and any reported statuses.
+func Flag(actor *models.Actor, report *models.Report) map[string]any {
+	objects := []any{report.Target.URI()}
+	for _, rs := range report.Statuses {
+		objects = append(objects, rs.Status.URI())
+	}
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("https://%s/reports/%d", actor.Domain, report.ID),
+		"type":     FLAG,
+		"actor":    actor.URI(),
+		"content":  report.Comment,
+		"object":   objects,
+	}
+}
+
+// Vote returns a Create activity of the actor's vote for the named option of the poll.
+// Votes are Notes with a name, but no content, in reply to the poll, and are addressed
+// only to the poll's author.
+func Vote(actor *models.Actor, poll *models.Status, optionID uint32, name string) map[string]any {
+	return Create(actor, map[string]any{
+		"id":           fmt.Sprintf("%s#votes/%d/%d", actor.URI(), poll.ObjectID, optionID),
+		"type":         "Note",
+		"attributedTo": actor.URI(),
+		"name":         name,
+		"inReplyTo":    poll.URI(),
+		"published":    time.Now().UTC().Format(time.RFC3339),
+		"to":           []any{poll.Actor.URI()},
+		"cc":           []any{},
+	})
+}
+
+// Unblock returns an Undo of the actor's Block of the object.
+func Unblock(actor, object *models.Actor) map[string]any {
+	block := Block(actor, object)
+	delete(block, "@context")
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#undos/blocks/%d", actor.URI(), snowflake.Now()),
+		"type":     UNDO,
+		"actor":    actor.URI(),
+		"object":   block,
+	}
+}
+
+// Accept returns an Accept of the follower's Follow of the actor. followID is the
+// id of the follower's Follow, which their server uses to match the Accept to it.
+func Accept(actor, follower *models.Actor, followID string) map[string]any {
 	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#accepts/follows/%d", actor.URI(), snowflake.Now()),
+		"type":     ACCEPT,
+		"actor":    actor.URI(),
+		"object":   inboundFollow(actor, follower, followID),
+	}
+}
+
+// Reject returns a Reject of the follower's Follow of the actor. followID is the
+// id of the follower's Follow, which their server uses to match the Reject to it.
+func Reject(actor, follower *models.Actor, followID string) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#rejects/follows/%d", actor.URI(), snowflake.Now()),
+		"type":     REJECT,
+		"actor":    actor.URI(),
+		"object":   inboundFollow(actor, follower, followID),
+	}
+}
+
+// inboundFollow returns the follower's Follow of the actor, with its original id if known.
+func inboundFollow(actor, follower *models.Actor, followID string) map[string]any {
+	follow := Follow(follower, actor, followID)
+	delete(follow, "@context")
+	return follow
+}
+
+// Follow returns a Follow of the object by the actor. followID is the id of the
+// Follow, if it is known.
+func Follow(actor, object *models.Actor, followID string) map[string]any {
+	follow := map[string]any{
 		"@context": "https://www.w3.org/ns/activitystreams",
 		"type":     FOLLOW,
 		"actor":    actor.URI(),
 		"object":   object.URI(),
 	}
+	if followID != "" {
+		follow["id"] = followID
+	}
+	return follow
 }
 
 func Like(actor *models.Actor, object string) map[string]any {
@@ -28,12 +247,30 @@ func Like(actor *models.Actor, object string) map[string]any {
 	}
 }
 
-func Unfollow(actor, object *models.Actor) map[string]any {
+// Unfollow returns an Undo of the actor's Follow of the object, whose id is followID.
+func Unfollow(actor, object *models.Actor, followID string) map[string]any {
+	follow := Follow(actor, object, followID)
+	delete(follow, "@context")
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"type":     UNDO,
+		"actor":    actor.URI(),
+		"object":   follow,
+	}
+}
+
+// Unannounce returns an Undo of the actor's Announce of the status.
+func Unannounce(actor *models.Actor, status *models.Status) map[string]any {
+	announce := Announce(actor, status)
+	delete(announce, "@context")
 	return map[string]any{
 		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#undos/announces/%d", actor.URI(), snowflake.Now()),
 		"type":     UNDO,
 		"actor":    actor.URI(),
-		"object":   Follow(actor, object),
+		"to":       announce["to"],
+		"cc":       announce["cc"],
+		"object":   announce,
 	}
 }
 
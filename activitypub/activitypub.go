@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/davecheney/pub/activitypub/activities"
@@ -26,54 +27,125 @@ type Env struct {
 	Logger   *slog.Logger
 	Client   *activitypub.Client
 	Instance *models.Instance
+	// AuthorizedFetch requires ActivityPub GET requests to be signed.
+	AuthorizedFetch bool
 }
 
 func (e *Env) Log() *slog.Logger {
 	return e.Logger
 }
 
-func Followers(env *Env, w http.ResponseWriter, r *http.Request) error {
-	var followers []*models.Relationship
-	query := env.DB.Joins("JOIN actors ON actors.object_id = relationships.target_id and actors.name = ? and actors.domain = ?", chi.URLParam(r, "name"), r.Host)
-	if err := query.Model(&models.Relationship{}).Scopes(models.PreloadRelationshipTarget).Find(&followers, "following = true").Error; err != nil {
-		return err
+// AuthorizedFetch wraps a handler which serves ActivityPub objects. If the Env
+// requires authorized fetch, requests must be signed by an actor whose domain is
+// not blocked. The instance actor is exempt so that remote servers, which may also
+// require signatures, can fetch the key this server signs its own requests with.
+func AuthorizedFetch(fn func(*Env, http.ResponseWriter, *http.Request) error) func(*Env, http.ResponseWriter, *http.Request) error {
+	return func(env *Env, w http.ResponseWriter, r *http.Request) error {
+		if env.AuthorizedFetch && !isInstanceActor(env, r) {
+			if r.Header.Get("Signature") == "" {
+				return httpx.Error(http.StatusUnauthorized, errors.New("request must be signed"))
+			}
+			if _, err := validateSignature(env.DB, r); err != nil {
+				return signatureError(err)
+			}
+		}
+		return fn(env, w, r)
 	}
-	return to.JSON(w, map[string]any{
-		"@context":   "https://www.w3.org/ns/activitystreams",
-		"id":         fmt.Sprintf("https://%s%s", r.Host, r.URL.Path),
-		"type":       "OrderedCollection",
-		"totalItems": len(followers),
-		"orderedItems": algorithms.Map(
-			followers,
-			func(r *models.Relationship) string {
-				return r.Actor.URI()
-			},
-		),
-	})
 }
 
+// isInstanceActor returns true if the request is for the instance actor's document.
+func isInstanceActor(env *Env, r *http.Request) bool {
+	uri := "https://" + r.Host + strings.TrimSuffix(r.URL.Path, "/")
+	return env.Instance != nil && env.Instance.Admin != nil && env.Instance.Admin.Actor != nil && env.Instance.Admin.Actor.URI() == uri
+}
+
+// Followers returns the collection of the local actor's followers.
+func Followers(env *Env, w http.ResponseWriter, r *http.Request) error {
+	return relationshipCollection(env, w, r, "followed_by")
+}
+
+// Following returns the collection of actors the local actor follows.
 func Following(env *Env, w http.ResponseWriter, r *http.Request) error {
-	var following []*models.Relationship
-	query := env.DB.Joins("JOIN actors ON actors.object_id = relationships.actor_id and actors.name = ? and actors.domain = ?", chi.URLParam(r, "name"), r.Host)
-	if err := query.Model(&models.Relationship{}).Scopes(models.PreloadRelationshipTarget).Find(&following, "following = true").Error; err != nil {
+	return relationshipCollection(env, w, r, "following")
+}
+
+// relationshipCollection returns the collection of actors to whom the local actor has the
+// relationship named by column, or a page of the collection if one is requested.
+// If the actor hides their collections, or has blocked the actor who signed the request,
+// only the number of items in the collection is shown.
+func relationshipCollection(env *Env, w http.ResponseWriter, r *http.Request, column string) error {
+	actor, err := models.NewActors(env.DB).Find(chi.URLParam(r, "name"), r.Host)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return httpx.Error(http.StatusNotFound, err)
+		}
+		return err
+	}
+	hidden, err := models.NewAccounts(env.DB).HidesCollections(actor.ObjectID)
+	if err != nil {
+		return err
+	}
+	if r.Header.Get("Signature") != "" {
+		signer, err := validateSignature(env.DB, r)
+		if err != nil {
+			return signatureError(err)
+		}
+		var blocks int64
+		if err := env.DB.Model(&models.Relationship{}).Where("actor_id = ? and target_id = ? and (blocking = true or blocked_by = true)", actor.ObjectID, signer.ObjectID).Count(&blocks).Error; err != nil {
+			return err
+		}
+		hidden = hidden || blocks > 0
+	}
+
+	relationships := func() *gorm.DB {
+		return env.DB.Model(&models.Relationship{}).Where("actor_id = ? and "+column+" = true", actor.ObjectID)
+	}
+	var count int64
+	if err := relationships().Count(&count).Error; err != nil {
+		return err
+	}
+	id := fmt.Sprintf("https://%s%s", r.Host, r.URL.Path)
+	if !parseBool(r, "page") {
+		resp := map[string]any{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         id,
+			"type":       "OrderedCollection",
+			"totalItems": count,
+		}
+		if !hidden {
+			resp["first"] = id + "?page=true"
+		}
+		return to.JSON(w, resp)
+	}
+	if hidden {
+		return httpx.Error(http.StatusForbidden, errors.New("collection is hidden"))
+	}
+
+	var page []*models.Relationship
+	if err := relationships().Scopes(models.PaginateRelationship(r), models.PreloadRelationshipTarget).Find(&page).Error; err != nil {
 		return err
 	}
-	return to.JSON(w, map[string]any{
+	resp := map[string]any{
 		"@context":   "https://www.w3.org/ns/activitystreams",
-		"id":         fmt.Sprintf("https://%s%s", r.Host, r.URL.Path),
-		"type":       "OrderedCollection",
-		"totalItems": len(following),
-		"orderedItems": algorithms.Map(
-			following,
-			func(r *models.Relationship) string {
-				return r.Target.URI()
-			},
-		),
-	})
+		"id":         fmt.Sprintf("https://%s%s", r.Host, r.URL.RequestURI()),
+		"type":       "OrderedCollectionPage",
+		"totalItems": count,
+		"partOf":     id,
+		"orderedItems": algorithms.Map(page, func(rel *models.Relationship) string {
+			return rel.Target.URI()
+		}),
+	}
+	if len(page) > 0 {
+		resp["next"] = fmt.Sprintf("%s?max_id=%d&page=true", id, page[len(page)-1].TargetID)
+		resp["prev"] = fmt.Sprintf("%s?min_id=%d&page=true", id, page[0].TargetID)
+	}
+	return to.JSON(w, resp)
 }
 
+// CollectionsShow returns the named collection of the local actor; featured,
+// the actor's pinned statuses, or tags, the hashtags the actor features.
 func CollectionsShow(env *Env, w http.ResponseWriter, r *http.Request) error {
-	_, err := models.NewActors(env.DB).Find(chi.URLParam(r, "name"), r.Host)
+	actor, err := models.NewActors(env.DB).Find(chi.URLParam(r, "name"), r.Host)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return httpx.Error(http.StatusNotFound, err)
@@ -81,13 +153,51 @@ func CollectionsShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	return to.JSON(w, map[string]any{
-		"@context":     "https://www.w3.org/ns/activitystreams",
-		"id":           fmt.Sprintf("https://%s%s", r.Host, r.URL.Path),
-		"type":         "OrderedCollection",
-		"totalItems":   0,
-		"orderedItems": []any{},
-	})
+	id := fmt.Sprintf("https://%s%s", r.Host, r.URL.Path)
+	switch chi.URLParam(r, "collection") {
+	case "featured":
+		var pinned []*models.Object
+		query := env.DB.Joins("JOIN statuses ON statuses.object_id = objects.id AND statuses.actor_id = ? AND statuses.visibility IN ?", actor.ObjectID, []string{"public", "unlisted"})
+		query = query.Joins("JOIN reactions ON reactions.status_id = statuses.object_id AND reactions.actor_id = statuses.actor_id AND reactions.pinned = true")
+		if err := query.Order("objects.id desc").Find(&pinned).Error; err != nil {
+			return err
+		}
+		return to.JSON(w, map[string]any{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         id,
+			"type":       "OrderedCollection",
+			"totalItems": len(pinned),
+			"orderedItems": algorithms.Map(pinned, func(obj *models.Object) map[string]any {
+				return obj.Properties
+			}),
+		})
+	case "tags":
+		featured, err := models.NewFeaturedTags(env.DB).FindByActor(actor)
+		if err != nil {
+			return err
+		}
+		return to.JSON(w, map[string]any{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         id,
+			"type":       "Collection",
+			"totalItems": len(featured),
+			"items": algorithms.Map(featured, func(ft *models.FeaturedTag) map[string]any {
+				return map[string]any{
+					"type": "Hashtag",
+					"href": fmt.Sprintf("https://%s/tags/%s", r.Host, ft.Tag.Name),
+					"name": "#" + ft.Tag.Name,
+				}
+			}),
+		})
+	default:
+		return to.JSON(w, map[string]any{
+			"@context":     "https://www.w3.org/ns/activitystreams",
+			"id":           id,
+			"type":         "OrderedCollection",
+			"totalItems":   0,
+			"orderedItems": []any{},
+		})
+	}
 }
 
 func stringFromAny(v any) string {
@@ -143,8 +253,213 @@ func parseBool(r *http.Request, key string) bool {
 	}
 }
 
-// Follow sends a follow request from the Account to the Target Actor's inbox.
-func Follow(ctx context.Context, follower *models.Account, target *models.Actor) error {
+// Create sends a create activity for the object from the Account to the Target Actor's inbox.
+func Create(ctx context.Context, author *models.Account, object map[string]any, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	c, err := activitypub.NewClient(author)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Create(author.Actor, object))
+}
+
+// Delete sends a delete activity for the tombstone from the Account to the Target Actor's inbox.
+func Delete(ctx context.Context, author *models.Account, tombstone map[string]any, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	c, err := activitypub.NewClient(author)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Delete(author.Actor, tombstone))
+}
+
+// Announce sends an announce of the Status from the Account to the Recipient Actor's inbox.
+func Announce(ctx context.Context, reblogger *models.Account, target *models.Status, recipient *models.Actor) error {
+	inbox := recipient.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", recipient.URI())
+	}
+	c, err := activitypub.NewClient(reblogger)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Announce(reblogger.Actor, target))
+}
+
+// Unannounce sends an undo announce of the Status from the Account to the Recipient Actor's inbox.
+func Unannounce(ctx context.Context, reblogger *models.Account, target *models.Status, recipient *models.Actor) error {
+	inbox := recipient.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", recipient.URI())
+	}
+	c, err := activitypub.NewClient(reblogger)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Unannounce(reblogger.Actor, target))
+}
+
+// Add sends an add of the Status to the Account's featured collection to the Recipient Actor's inbox.
+func Add(ctx context.Context, account *models.Account, target *models.Status, recipient *models.Actor) error {
+	inbox := recipient.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", recipient.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Add(account.Actor, target))
+}
+
+// Remove sends a remove of the Status from the Account's featured collection to the Recipient Actor's inbox.
+func Remove(ctx context.Context, account *models.Account, target *models.Status, recipient *models.Actor) error {
+	inbox := recipient.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", recipient.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Remove(account.Actor, target))
+}
+
+// Accept sends an accept of the Target Actor's follow from the Account to the Target Actor's inbox.
+func Accept(ctx context.Context, account *models.Account, follower *models.Actor, followID string) error {
+	inbox := follower.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", follower.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Accept(account.Actor, follower, followID))
+}
+
+// Reject sends a reject of the Target Actor's follow from the Account to the Target Actor's inbox.
+func Reject(ctx context.Context, account *models.Account, follower *models.Actor, followID string) error {
+	inbox := follower.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", follower.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Reject(account.Actor, follower, followID))
+}
+
+// Block sends a block request from the Account to the Target Actor's inbox.
+func Block(ctx context.Context, blocker *models.Account, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	c, err := activitypub.NewClient(blocker)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Block(blocker.Actor, target))
+}
+
+// Flag sends the report from the Account, usually the instance's admin account,
+// to the reported Actor's inbox.
+func Flag(ctx context.Context, account *models.Account, report *models.Report) error {
+	inbox := report.Target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", report.Target.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Flag(account.Actor, report))
+}
+
+// Vote sends the Account's votes for the options of the poll to the poll's author.
+func Vote(ctx context.Context, voter *models.Account, poll *models.Status, options []*models.StatusPollOption) error {
+	inbox := poll.Actor.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", poll.Actor.URI())
+	}
+	c, err := activitypub.NewClient(voter)
+	if err != nil {
+		return err
+	}
+	for _, option := range options {
+		if err := c.Post(ctx, inbox, activities.Vote(voter.Actor, poll, option.ID, option.Title)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unblock sends an undo block request from the Account to the Target Actor's inbox.
+func Unblock(ctx context.Context, blocker *models.Account, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	c, err := activitypub.NewClient(blocker)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Unblock(blocker.Actor, target))
+}
+
+// Update sends an update of the Account's actor to the Target Actor's inbox.
+func Update(ctx context.Context, account *models.Account, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Update(account.Actor, actorToObject(account.Actor)))
+}
+
+// UpdateObject sends an update of the edited object from the Account to the Target Actor's inbox.
+func UpdateObject(ctx context.Context, author *models.Account, object map[string]any, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	c, err := activitypub.NewClient(author)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Update(author.Actor, object))
+}
+
+// Move sends a move of the Account to the actor it has moved to, to the Target Actor's inbox.
+func Move(ctx context.Context, account *models.Account, target *models.Actor) error {
+	inbox := target.Inbox()
+	if inbox == "" {
+		return fmt.Errorf("no inbox found for %s", target.URI())
+	}
+	movedTo := account.Actor.MovedTo()
+	if movedTo == "" {
+		return fmt.Errorf("%s has not moved", account.Actor.URI())
+	}
+	c, err := activitypub.NewClient(account)
+	if err != nil {
+		return err
+	}
+	return c.Post(ctx, inbox, activities.Move(account.Actor, movedTo))
+}
+
+// Follow sends a follow request, whose id is followID, from the Account to the Target Actor's inbox.
+func Follow(ctx context.Context, follower *models.Account, target *models.Actor, followID string) error {
 	inbox := target.Inbox()
 	if inbox == "" {
 		return fmt.Errorf("no inbox found for %s", target.URI())
@@ -153,11 +468,11 @@ func Follow(ctx context.Context, follower *models.Account, target *models.Actor)
 	if err != nil {
 		return err
 	}
-	return c.Post(ctx, inbox, activities.Follow(follower.Actor, target))
+	return c.Post(ctx, inbox, activities.Follow(follower.Actor, target, followID))
 }
 
-// Unfollow sends an unfollow request from the Account to the Target Actor's inbox.
-func Unfollow(ctx context.Context, follower *models.Account, target *models.Actor) error {
+// Unfollow sends an undo of the follow request whose id is followID from the Account to the Target Actor's inbox.
+func Unfollow(ctx context.Context, follower *models.Account, target *models.Actor, followID string) error {
 	inbox := target.Inbox()
 	if inbox == "" {
 		return fmt.Errorf("no inbox found for %s", target.URI())
@@ -166,7 +481,7 @@ func Unfollow(ctx context.Context, follower *models.Account, target *models.Acto
 	if err != nil {
 		return err
 	}
-	return c.Post(ctx, inbox, activities.Unfollow(follower.Actor, target))
+	return c.Post(ctx, inbox, activities.Unfollow(follower.Actor, target, followID))
 }
 
 // Like sends a like request from the Account to the Statuses Actor's inbox.
@@ -0,0 +1,218 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davecheney/pub/internal/httpsig"
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	require := require.New(t)
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Warn),
+	})
+	require.NoError(err)
+	require.NoError(db.AutoMigrate(models.AllTables()...))
+	return db
+}
+
+// testSigner signs the requests of a test client.
+type testSigner struct {
+	key *rsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return &testSigner{key: key}
+}
+
+func (s *testSigner) PublicKeyID() string               { return "https://example.com/u/admin#main-key" }
+func (s *testSigner) PrivKey() (*rsa.PrivateKey, error) { return s.key, nil }
+
+// mockActor creates a remote actor with the given id, and any other properties.
+func mockActor(t *testing.T, tx *gorm.DB, id string, props map[string]any) *models.Actor {
+	t.Helper()
+	require := require.New(t)
+
+	obj := map[string]any{
+		"id":                id,
+		"type":              "Person",
+		"published":         time.Now().Format(time.RFC3339),
+		"preferredUsername": id[strings.LastIndex(id, "/")+1:],
+		"inbox":             id + "/inbox",
+	}
+	for k, v := range props {
+		obj[k] = v
+	}
+	require.NoError(tx.Create(&models.Object{Properties: obj}).Error)
+	actor, err := models.NewActors(tx).FindByURI(id)
+	require.NoError(err)
+	return actor
+}
+
+func TestAuthorizedFetch(t *testing.T) {
+	admin := &models.Actor{Name: "admin", Domain: "example.com", Type: "LocalService", Object: &models.ActorObject{}}
+	admin.Object.Properties.ID = "https://example.com/u/admin"
+	env := &Env{
+		Instance: &models.Instance{
+			Domain: "example.com",
+			Admin:  &models.Account{Actor: admin},
+		},
+		AuthorizedFetch: true,
+	}
+	ok := AuthorizedFetch(func(env *Env, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "https://example.com/u/alice", nil)
+		err := ok(env, httptest.NewRecorder(), r)
+		var se *httpx.StatusError
+		require.ErrorAs(t, err, &se)
+		require.Equal(t, http.StatusUnauthorized, se.Status())
+	})
+	t.Run("unsigned request for the instance actor", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "https://example.com/u/admin", nil)
+		require.NoError(t, ok(env, httptest.NewRecorder(), r))
+	})
+	t.Run("request signed by a blocked domain", func(t *testing.T) {
+		db := setupTestDB(t)
+		_, err := models.NewDomainBlocks(db).Create("blocked.example", "spam")
+		require.NoError(t, err)
+		env := *env
+		env.DB = db
+
+		r := httptest.NewRequest("GET", "https://example.com/u/alice", nil)
+		r.Header.Set("Accept", "application/activity+json")
+		require.NoError(t, httpsig.Sign(r, httpsig.Cavage, "https://blocked.example/users/eve#main-key", newTestSigner(t).key, nil))
+		err = ok(&env, httptest.NewRecorder(), r)
+		var se *httpx.StatusError
+		require.ErrorAs(t, err, &se)
+		require.Equal(t, http.StatusForbidden, se.Status())
+	})
+	t.Run("authorized fetch disabled", func(t *testing.T) {
+		env := *env
+		env.AuthorizedFetch = false
+		r := httptest.NewRequest("GET", "https://example.com/u/alice", nil)
+		require.NoError(t, ok(&env, httptest.NewRecorder(), r))
+	})
+}
+
+func TestCollectionsShow(t *testing.T) {
+	db := setupTestDB(t)
+
+	get := func(t *testing.T, env *Env, name, collection string) (map[string]any, error) {
+		r := httptest.NewRequest("GET", "https://example.com/u/"+name+"/collections/"+collection, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", name)
+		rctx.URLParams.Add("collection", collection)
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		if err := CollectionsShow(env, w, r); err != nil {
+			return nil, err
+		}
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body, nil
+	}
+	status := func(t *testing.T, tx *gorm.DB, actor *models.Actor, n int, to string) *models.Status {
+		id := fmt.Sprintf("%s/statuses/%d", actor.URI(), n)
+		require.NoError(t, tx.Create(&models.Object{Properties: map[string]any{
+			"id":           id,
+			"type":         "Note",
+			"attributedTo": actor.URI(),
+			"published":    time.Now().Add(time.Duration(n) * time.Second).UTC().Format(time.RFC3339),
+			"content":      fmt.Sprintf("<p>status %d</p>", n),
+			"to":           []any{to},
+		}}).Error)
+		status, err := models.NewStatuses(tx).FindByURI(id)
+		require.NoError(t, err)
+		return status
+	}
+
+	t.Run("featured lists the actor's public pins", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := mockActor(t, tx, "https://example.com/u/alice", nil)
+		pinned := status(t, tx, alice, 1, "https://www.w3.org/ns/activitystreams#Public")
+		status(t, tx, alice, 2, "https://www.w3.org/ns/activitystreams#Public")
+		private := status(t, tx, alice, 3, alice.URI()+"/followers")
+		reactions := models.NewReactions(tx)
+		_, err := reactions.Pin(pinned, alice)
+		require.NoError(err)
+		_, err = reactions.Pin(private, alice)
+		require.NoError(err)
+
+		body, err := get(t, &Env{DB: tx}, "alice", "featured")
+		require.NoError(err)
+		require.Equal("https://example.com/u/alice/collections/featured", body["id"])
+		require.EqualValues(1, body["totalItems"])
+		items := body["orderedItems"].([]any)
+		require.Len(items, 1)
+		require.Equal(pinned.URI(), items[0].(map[string]any)["id"])
+	})
+
+	t.Run("tags lists the actor's featured tags", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := mockActor(t, tx, "https://example.com/u/alice", nil)
+		_, err := models.NewFeaturedTags(tx).Create(alice, "#golang")
+		require.NoError(err)
+
+		body, err := get(t, &Env{DB: tx}, "alice", "tags")
+		require.NoError(err)
+		require.EqualValues(1, body["totalItems"])
+		require.Equal([]any{map[string]any{
+			"type": "Hashtag",
+			"href": "https://example.com/tags/golang",
+			"name": "#golang",
+		}}, body["items"])
+	})
+
+	t.Run("other collections are empty", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		mockActor(t, tx, "https://example.com/u/alice", nil)
+		body, err := get(t, &Env{DB: tx}, "alice", "devices")
+		require.NoError(err)
+		require.EqualValues(0, body["totalItems"])
+	})
+
+	t.Run("unknown actor", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		_, err := get(t, &Env{DB: tx}, "bob", "featured")
+		var se *httpx.StatusError
+		require.ErrorAs(t, err, &se)
+		require.Equal(t, http.StatusNotFound, se.Status())
+	})
+}
@@ -0,0 +1,116 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// maxCollectionPages is the number of pages of a remote collection walked before
+// giving up, so a collection of empty, or endless, pages cannot stall a worker.
+const maxCollectionPages = 10
+
+// Backfill walks the actor's outbox, newest first, ingesting up to limit of the
+// Notes it has created and the statuses it has announced. Items which cannot be
+// ingested are logged and skipped.
+func Backfill(ctx context.Context, logger *slog.Logger, db *gorm.DB, client *activitypub.Client, actor *models.Actor, limit int) error {
+	outbox := actor.OutboxURL()
+	if outbox == "" {
+		return fmt.Errorf("actor %s has no outbox", actor.URI())
+	}
+	processor := &inboxProcessor{
+		logger: logger,
+		db:     db.WithContext(activitypub.WithClient(ctx, client)),
+		client: client,
+	}
+	var collection map[string]any
+	if err := client.Fetch(ctx, outbox, &collection); err != nil {
+		return err
+	}
+	page := collection
+	if _, ok := collectionItems(collection); !ok {
+		// items are paged.
+		first, err := processor.dereference(collection["first"])
+		if err != nil {
+			return err
+		}
+		page = first
+	}
+	seen := 0
+	for pages := 1; page != nil; pages++ {
+		items, _ := collectionItems(page)
+		for _, item := range items {
+			if seen == limit {
+				break
+			}
+			seen++
+			if err := processor.backfillItem(actor, item); err != nil {
+				logger.Info("backfill", "actor", actor.URI(), "error", err)
+			}
+		}
+		if seen == limit || pages == maxCollectionPages {
+			break
+		}
+		next, err := processor.dereference(page["next"])
+		if err != nil {
+			return err
+		}
+		page = next
+	}
+	return nil
+}
+
+// dereference returns the object, fetching it if it is a link.
+// If there is no object, nil is returned.
+func (i *inboxProcessor) dereference(v any) (map[string]any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		return v, nil
+	case string:
+		var page map[string]any
+		err := i.client.Fetch(i.db.Statement.Context, v, &page)
+		return page, err
+	default:
+		return nil, nil
+	}
+}
+
+// backfillItem ingests an activity from the actor's outbox.
+func (i *inboxProcessor) backfillItem(actor *models.Actor, item any) error {
+	act, err := i.dereference(item)
+	if err != nil {
+		return err
+	}
+	if act == nil || stringFromAny(act["actor"]) != actor.URI() {
+		return fmt.Errorf("item %v was not performed by the actor", item)
+	}
+	switch act["type"] {
+	case "Create":
+		obj, err := i.dereference(act["object"])
+		if err != nil {
+			return err
+		}
+		if obj == nil || stringFromAny(obj["attributedTo"]) != actor.URI() {
+			return fmt.Errorf("create %s: object is not attributed to the actor", act["id"])
+		}
+		switch obj["type"] {
+		case "Note", "Question":
+			return i.createObject(obj)
+		default:
+			// ignore other object types
+			return nil
+		}
+	case "Announce":
+		if _, ok := act["object"].(string); !ok {
+			return fmt.Errorf("announce %s: object is not a link", act["id"])
+		}
+		return i.processAnnounce(act)
+	default:
+		// ignore other activities
+		return nil
+	}
+}
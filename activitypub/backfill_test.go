@@ -0,0 +1,105 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestBackfill(t *testing.T) {
+	db := setupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := activitypub.NewClient(newTestSigner(t))
+	require.NoError(t, err)
+
+	create := func(actor string, n int) map[string]any {
+		return map[string]any{
+			"id":    fmt.Sprintf("%s/statuses/%d/activity", actor, n),
+			"type":  "Create",
+			"actor": actor,
+			"object": map[string]any{
+				"id":           fmt.Sprintf("%s/statuses/%d", actor, n),
+				"type":         "Note",
+				"attributedTo": actor,
+				"published":    time.Now().Add(-time.Duration(n) * time.Minute).UTC().Format(time.RFC3339),
+				"content":      fmt.Sprintf("<p>status %d</p>", n),
+				"to":           []any{"https://www.w3.org/ns/activitystreams#Public"},
+			},
+		}
+	}
+
+	t.Run("walks the pages of the outbox up to the limit", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		var srv *httptest.Server
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor := srv.URL + "/users/bob"
+			w.Header().Set("Content-Type", "application/activity+json")
+			switch r.URL.Query().Get("page") {
+			case "":
+				json.NewEncoder(w).Encode(map[string]any{"type": "OrderedCollection", "first": actor + "/outbox?page=1"})
+			case "1":
+				json.NewEncoder(w).Encode(map[string]any{
+					"type":         "OrderedCollectionPage",
+					"orderedItems": []any{create(actor, 1), create(actor, 2)},
+					"next":         actor + "/outbox?page=2",
+				})
+			case "2":
+				json.NewEncoder(w).Encode(map[string]any{
+					"type":         "OrderedCollectionPage",
+					"orderedItems": []any{create(actor, 3), create(actor, 4)},
+				})
+			}
+		}))
+		defer srv.Close()
+
+		bob := mockActor(t, tx, srv.URL+"/users/bob", map[string]any{"outbox": srv.URL + "/users/bob/outbox"})
+		require.NoError(Backfill(context.Background(), logger, tx, client, bob, 3))
+
+		var statuses []models.Status
+		require.NoError(tx.Preload("Object").Where("actor_id = ?", bob.ObjectID).Order("object_id desc").Find(&statuses).Error)
+		require.Len(statuses, 3)
+		require.Equal(srv.URL+"/users/bob/statuses/1", statuses[0].URI())
+		require.Equal(srv.URL+"/users/bob/statuses/3", statuses[2].URI())
+	})
+
+	t.Run("stops walking an endless outbox", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		var pages int
+		var srv *httptest.Server
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/activity+json")
+			if r.URL.Query().Get("page") == "" {
+				json.NewEncoder(w).Encode(map[string]any{"type": "OrderedCollection", "first": srv.URL + "/users/bob/outbox?page=1"})
+				return
+			}
+			// every page is empty and has a next page.
+			pages++
+			json.NewEncoder(w).Encode(map[string]any{
+				"type":         "OrderedCollectionPage",
+				"orderedItems": []any{},
+				"next":         fmt.Sprintf("%s/users/bob/outbox?page=%d", srv.URL, pages+1),
+			})
+		}))
+		defer srv.Close()
+
+		bob := mockActor(t, tx, srv.URL+"/users/bob", map[string]any{"outbox": srv.URL + "/users/bob/outbox"})
+		require.NoError(Backfill(context.Background(), logger, tx, client, bob, 20))
+		require.Equal(maxCollectionPages, pages)
+	})
+}
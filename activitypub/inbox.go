@@ -1,68 +1,131 @@
 package activitypub
 
 import (
+	"bytes"
+	"context"
 	"crypto"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/internal/httpsig"
 	"github.com/davecheney/pub/internal/httpx"
 	"github.com/davecheney/pub/models"
-	"github.com/go-fed/httpsig"
 	"github.com/go-json-experiment/json"
 	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func NewInbox(db *gorm.DB) *InboxController {
-	return &InboxController{}
+// MinSeenActivityTTL is the shortest time seen activities must be remembered
+// so that any replay of a request with an acceptable Date header is recognised.
+const MinSeenActivityTTL = httpsig.MaxSignatureAge + httpsig.MaxClockSkew
+
+// NewInbox returns an InboxController which discards activities already seen
+// within seenTTL, which should be at least MinSeenActivityTTL.
+func NewInbox(seenTTL time.Duration) *InboxController {
+	return &InboxController{
+		seenTTL: seenTTL,
+	}
 }
 
 type InboxController struct {
+	seenTTL time.Duration
 }
 
+// Create verifies the signature of the incoming activity and queues it for
+// processing by the InboxRequestProcessor.
 func (i *InboxController) Create(env *Env, w http.ResponseWriter, r *http.Request) error {
+	// the body is read whole, and restored, so the signature can check its digest.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return httpx.Error(http.StatusBadRequest, err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
 	var act map[string]any
-	if err := json.UnmarshalRead(r.Body, &act); err != nil {
+	if err := json.Unmarshal(body, &act); err != nil {
 		return httpx.Error(http.StatusBadRequest, err)
 	}
 	id, ok := act["id"].(string)
 	if !ok {
-		return errors.New("missing id")
+		return httpx.Error(http.StatusBadRequest, errors.New("missing id"))
+	}
+	actor := stringFromAny(act["actor"])
+	if actor == "" {
+		return httpx.Error(http.StatusBadRequest, errors.New("missing actor"))
 	}
 
-	// if we need to make an activity pub request, we need to sign it with the
-	// instance's admin account.
-	processor := &inboxProcessor{
-		logger: env.Logger.With("instance", env.Instance.Domain),
-		req:    r,
-		db:     env.DB,
-		client: env.Client,
+	if act["type"] == "Delete" {
+		// Delete is a special case, as we may not have the actor in our database,
+		// and their key may no longer be available. If we don't know the actor,
+		// there is nothing to delete.
+		var count int64
+		if err := env.DB.Model(&models.Object{}).Where("uri = ?", actor).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return nil
+		}
+	}
+	signer, err := validateSignature(env.DB, r)
+	if err != nil {
+		return signatureError(err)
+	}
+	if signer.URI() != actor {
+		// an actor may only deliver their own activities.
+		return httpx.Error(http.StatusUnauthorized, fmt.Errorf("activity of %s signed by %s", actor, signer.URI()))
 	}
 
-	if err := processor.processActivity(act); err != nil {
-		return fmt.Errorf("processActivity failed: %s: %w ", id, err)
+	// the activity is only recorded as seen if it is queued, so that a retry
+	// of a request which failed to queue is not discarded as a duplicate.
+	var seen bool
+	if err := env.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		seen, err = models.NewSeenActivities(tx).Seen(id, i.seenTTL)
+		if err != nil || seen {
+			return err
+		}
+		return tx.Create(&models.ActivitypubInboxRequest{
+			ActorURI:   actor,
+			ActivityID: id,
+			Activity:   act,
+		}).Error
+	}); err != nil {
+		return fmt.Errorf("queue activity: %s: %w", id, err)
+	}
+	if seen {
+		env.Logger.Info("discarding duplicate activity", "id", id)
 	}
 	w.WriteHeader(http.StatusAccepted)
 	return nil
 }
 
+// ProcessActivity processes an activity taken from the inbox queue.
+// The signature of the activity must have been verified when it was received,
+// and the signer must be the actor of the activity.
+func ProcessActivity(ctx context.Context, logger *slog.Logger, db *gorm.DB, client *activitypub.Client, act map[string]any) error {
+	processor := &inboxProcessor{
+		logger: logger,
+		db:     db.WithContext(activitypub.WithClient(ctx, client)),
+		client: client,
+	}
+	return processor.processActivity(act)
+}
+
 type inboxProcessor struct {
 	logger *slog.Logger
-	req    *http.Request
 	db     *gorm.DB
 	client *activitypub.Client
 }
 
-// processActivity processes an activity. If the activity can be handled without
-// blocking, it is handled immediately. If the activity requires blocking, it is
-// queued for later processing.
+// processActivity processes an activity.
 func (i *inboxProcessor) processActivity(act map[string]any) error {
 	typ, ok := act["type"].(string)
 	if !ok {
@@ -70,54 +133,64 @@ func (i *inboxProcessor) processActivity(act map[string]any) error {
 	}
 	i.logger = i.logger.With("id", stringFromAny(act["id"]), "type", typ)
 	i.logger.Info("processActivity")
+	actor := stringFromAny(act["actor"])
 	switch typ {
-	case "":
-		return httpx.Error(http.StatusBadRequest, errors.New("missing type"))
 	case "Delete":
-		// Delete is a special case, as we may not have the actor in our database.
-		// In that case, check the actor exists locally, and if it does, then
-		// validate the signature.
-		return i.processDelete(act)
-	default:
-		if err := i.validateSignature(); err != nil {
-			return httpx.Error(http.StatusUnauthorized, err)
-		}
-		switch typ {
-		case "Create":
-			create, ok := act["object"].(map[string]any)
-			if !ok {
-				return errors.New("create: missing object")
-			}
-			return i.processCreate(create)
-		case "Announce":
-			return i.processAnnounce(act)
-		case "Undo":
-			undo, ok := act["object"].(map[string]any)
-			if !ok {
-				return errors.New("undo: missing object")
-			}
-			return i.processUndo(undo)
-		case "Update":
-			update, ok := act["object"].(map[string]any)
-			if !ok {
-				return errors.New("update: missing object")
-			}
-			return i.processUpdate(update)
-		case "Follow":
-			return i.processFollow(act)
-		case "Accept":
-			accept, ok := act["object"].(map[string]any)
-			if !ok {
-				return errors.New("accept: missing object")
-			}
-			return i.processAccept(accept)
-		case "Add":
-			return i.processAdd(act)
-		case "Remove":
-			return i.processRemove(act)
-		default:
-			return errors.New("unknown activity type: " + typ)
+		return i.processDelete(actor, act)
+	case "Create":
+		create, ok := act["object"].(map[string]any)
+		if !ok {
+			return errors.New("create: missing object")
+		}
+		return i.processCreate(actor, create)
+	case "Announce":
+		if err := checkOrigin(actor, stringFromAny(act["id"])); err != nil {
+			return err
+		}
+		return i.processAnnounce(act)
+	case "Undo":
+		undo, ok := act["object"].(map[string]any)
+		if !ok {
+			return errors.New("undo: missing object")
+		}
+		if undoer := stringFromAny(undo["actor"]); undoer != actor {
+			return fmt.Errorf("undo: %s cannot undo an activity of %q", actor, undoer)
+		}
+		return i.processUndo(undo)
+	case "Update":
+		update, ok := act["object"].(map[string]any)
+		if !ok {
+			return errors.New("update: missing object")
+		}
+		return i.processUpdate(actor, update)
+	case "Follow":
+		return i.processFollow(act)
+	case "Block":
+		return i.processBlock(act)
+	case "Like":
+		return i.processLike(act)
+	case "Move":
+		return i.processMove(act)
+	case "Accept":
+		accept, err := i.answeredObject(act["object"])
+		if err != nil {
+			return fmt.Errorf("accept: %w", err)
+		}
+		return i.processAccept(stringFromAny(act["actor"]), accept)
+	case "Reject":
+		reject, err := i.answeredObject(act["object"])
+		if err != nil {
+			return fmt.Errorf("reject: %w", err)
 		}
+		return i.processReject(stringFromAny(act["actor"]), reject)
+	case "Flag":
+		return i.processFlag(act)
+	case "Add":
+		return i.processAdd(act)
+	case "Remove":
+		return i.processRemove(act)
+	default:
+		return errors.New("unknown activity type: " + typ)
 	}
 }
 
@@ -128,12 +201,19 @@ func (i *inboxProcessor) processUndo(obj map[string]any) error {
 		return i.processUndoAnnounce(obj)
 	case "Follow":
 		return i.processUndoFollow(obj)
+	case "Block":
+		return i.processUndoBlock(obj)
+	case "Like":
+		return i.processUndoLike(obj)
 	default:
 		return fmt.Errorf("unknown undo object type: %q", typ)
 	}
 }
 
 func (i *inboxProcessor) processUndoAnnounce(obj map[string]any) error {
+	if err := checkOrigin(stringFromAny(obj["actor"]), stringFromAny(obj["id"])); err != nil {
+		return err
+	}
 	var target models.Object
 	if err := i.db.Where("uri = ?", stringFromAny(obj["id"])).Take(&target).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -142,6 +222,9 @@ func (i *inboxProcessor) processUndoAnnounce(obj map[string]any) error {
 		}
 		return err
 	}
+	if announcer := stringFromAny(target.Properties["actor"]); announcer != stringFromAny(obj["actor"]) {
+		return fmt.Errorf("undo: %s cannot undo an announce of %q", stringFromAny(obj["actor"]), announcer)
+	}
 	return i.db.Delete(&target).Error
 }
 
@@ -160,6 +243,153 @@ func (i *inboxProcessor) processUndoFollow(body map[string]any) error {
 	return err
 }
 
+// processBlock records that the local target has been blocked by the remote actor.
+// Any follow relationship between them is removed.
+func (i *inboxProcessor) processBlock(act map[string]any) error {
+	actor, target, err := i.blockParticipants(act)
+	if err != nil || target == nil {
+		return err
+	}
+	_, err = models.NewRelationships(i.db).Block(actor, target)
+	return err
+}
+
+func (i *inboxProcessor) processUndoBlock(body map[string]any) error {
+	actor, target, err := i.blockParticipants(body)
+	if err != nil || target == nil {
+		return err
+	}
+	_, err = models.NewRelationships(i.db).Unblock(actor, target)
+	return err
+}
+
+// blockParticipants returns the actor and the target of the block. Blocks of
+// actors which are unknown, or not local, are no concern of this server, and
+// the target is nil.
+func (i *inboxProcessor) blockParticipants(block map[string]any) (*models.Actor, *models.Actor, error) {
+	actors := models.NewActors(i.db)
+	actor, err := actors.FindByURI(stringFromAny(block["actor"]))
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := actors.FindByURI(stringFromAny(block["object"]))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		i.logger.Info("blockParticipants: ignoring block of an unknown actor", "actor", actor.URI())
+		return actor, nil, nil
+	case err != nil:
+		return nil, nil, err
+	case target.IsRemote():
+		i.logger.Info("blockParticipants: ignoring block of a remote actor", "actor", actor.URI())
+		return actor, nil, nil
+	}
+	return actor, target, nil
+}
+
+// processFlag records a report from another server about a local actor, and any
+// of their statuses, in the moderation queue. Flags about remote actors are ignored.
+func (i *inboxProcessor) processFlag(act map[string]any) error {
+	actors := models.NewActors(i.db)
+	actor, err := actors.FindOrCreateByURI(stringFromAny(act["actor"]))
+	if err != nil {
+		return err
+	}
+	objects := anyToSlice(act["object"])
+	if object := stringFromAny(act["object"]); object != "" {
+		objects = []any{object}
+	}
+	var target *models.Actor
+	var statuses []*models.Status
+	for _, object := range objects {
+		uri := stringFromAny(object)
+		reported, err := actors.FindByURI(uri)
+		switch {
+		case err == nil:
+			if target == nil && reported.IsLocal() {
+				target = reported
+			}
+			continue
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+		st, err := models.NewStatuses(i.db).FindByURI(uri)
+		switch {
+		case err == nil:
+			statuses = append(statuses, st)
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+	}
+	if target == nil && len(statuses) > 0 {
+		target = statuses[0].Actor
+	}
+	if target == nil || target.IsRemote() {
+		i.logger.Info("processFlag: ignoring flag without a local actor", "actor", actor.URI())
+		return nil
+	}
+	// only the target's own statuses may be attached to the report.
+	statuses = slices.DeleteFunc(statuses, func(st *models.Status) bool {
+		return st.ActorID != target.ObjectID
+	})
+	_, err = models.NewReports(i.db).Flag(actor, target, statuses, stringFromAny(act["content"]), stringFromAny(act["id"]))
+	return err
+}
+
+// processMove moves the follows of local actors from the actor to the target
+// of the move, if the target confirms the move by listing the actor as an alias.
+func (i *inboxProcessor) processMove(act map[string]any) error {
+	origin := stringFromAny(act["actor"])
+	if object := stringFromAny(act["object"]); object != origin {
+		return fmt.Errorf("move: %q cannot move %q", origin, object)
+	}
+	actors := models.NewActors(i.db)
+	actor, err := actors.FindByURI(origin)
+	if err != nil {
+		return err
+	}
+	// fetch the target to see its current aliases.
+	target, err := actors.Fetch(stringFromAny(act["target"]))
+	if err != nil {
+		return err
+	}
+	return actors.Moved(actor, target)
+}
+
+// processLike records the remote actor's favourite of a local status.
+func (i *inboxProcessor) processLike(act map[string]any) error {
+	actor, err := models.NewActors(i.db).FindByURI(stringFromAny(act["actor"]))
+	if err != nil {
+		return err
+	}
+	status, err := models.NewStatuses(i.db).FindByURI(stringFromAny(act["object"]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// not a status we know about, ignore it
+			return nil
+		}
+		return err
+	}
+	_, err = models.NewReactions(i.db).Favourite(status, actor)
+	return err
+}
+
+func (i *inboxProcessor) processUndoLike(obj map[string]any) error {
+	actor, err := models.NewActors(i.db).FindByURI(stringFromAny(obj["actor"]))
+	if err != nil {
+		return err
+	}
+	status, err := models.NewStatuses(i.db).FindByURI(stringFromAny(obj["object"]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// already deleted
+			return nil
+		}
+		return err
+	}
+	_, err = models.NewReactions(i.db).Unfavourite(status, actor)
+	return err
+}
+
 func (i *inboxProcessor) processAnnounce(act map[string]any) error {
 	return i.createObject(act)
 }
@@ -257,10 +487,61 @@ func (i *inboxProcessor) processRemovePin(act map[string]any) error {
 	}
 }
 
-func (i *inboxProcessor) processCreate(create map[string]any) error {
+// processCreate stores the object created by the actor, or records the actor's
+// vote if the object is a vote on a local poll.
+func (i *inboxProcessor) processCreate(actor string, create map[string]any) error {
+	if err := checkAttribution(actor, create); err != nil {
+		return err
+	}
+	if isVote(create) {
+		ok, err := i.processVote(actor, create)
+		if err != nil || ok {
+			return err
+		}
+	}
 	return i.createObject(create)
 }
 
+// isVote reports whether the object is a vote on a poll; a Note with a name,
+// but no content, in reply to the poll.
+func isVote(obj map[string]any) bool {
+	return obj["type"] == "Note" && stringFromAny(obj["name"]) != "" &&
+		stringFromAny(obj["content"]) == "" && stringFromAny(obj["inReplyTo"]) != ""
+}
+
+// processVote records the actor's vote on a local poll, returning false if the
+// object is not in reply to a local poll.
+func (i *inboxProcessor) processVote(actor string, vote map[string]any) (bool, error) {
+	status, err := models.NewStatuses(i.db).FindByURI(stringFromAny(vote["inReplyTo"]))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if status.Poll == nil || status.Actor.IsRemote() {
+		return false, nil
+	}
+	choice := slices.IndexFunc(status.Poll.Options, func(option models.StatusPollOption) bool {
+		return option.Title == stringFromAny(vote["name"])
+	})
+	if choice < 0 {
+		return true, fmt.Errorf("vote: %q is not an option of %s", vote["name"], status.URI())
+	}
+	voter, err := models.NewActors(i.db).FindOrCreateByURI(actor)
+	if err != nil {
+		return true, err
+	}
+	err = models.NewPolls(i.db).Vote(status.Poll, voter, []int{choice})
+	switch {
+	case errors.Is(err, models.ErrPollExpired), errors.Is(err, models.ErrAlreadyVoted):
+		i.logger.Info("processVote: discarding vote", "voter", voter.URI(), "poll", status.URI(), "error", err)
+		return true, nil
+	default:
+		return true, err
+	}
+}
+
 func (i *inboxProcessor) createObject(props map[string]any) error {
 	obj := &models.Object{
 		Properties: props,
@@ -323,19 +604,91 @@ func focalPoint(obj map[string]any) models.FocalPoint {
 	}
 }
 
-func (i *inboxProcessor) processAccept(obj map[string]any) error {
+// answeredObject returns the object answered by an Accept or Reject. If the object
+// is referred to by its id, it must be a Follow whose id is known.
+func (i *inboxProcessor) answeredObject(object any) (map[string]any, error) {
+	switch object := object.(type) {
+	case map[string]any:
+		return object, nil
+	case string:
+		var follow models.Relationship
+		err := i.db.Preload("Actor").Preload("Actor.Object").Preload("Target").Preload("Target.Object").
+			Where("follow_uri = ?", object).Take(&follow).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("unknown object %q", object)
+			}
+			return nil, err
+		}
+		return map[string]any{
+			"id":     object,
+			"type":   "Follow",
+			"actor":  follow.Actor.URI(),
+			"object": follow.Target.URI(),
+		}, nil
+	default:
+		return nil, errors.New("missing object")
+	}
+}
+
+func (i *inboxProcessor) processAccept(actor string, obj map[string]any) error {
 	typ := stringFromAny(obj["type"])
 	switch typ {
 	case "Follow":
-		return i.processAcceptFollow(obj)
+		return i.processAcceptFollow(actor, obj)
 	default:
 		return fmt.Errorf("unknown accept object type: %q", typ)
 	}
 }
 
-func (i *inboxProcessor) processAcceptFollow(obj map[string]any) error {
-	// consume
-	return nil
+// processAcceptFollow records that the remote actor has accepted the local
+// actor's follow request.
+func (i *inboxProcessor) processAcceptFollow(actor string, obj map[string]any) error {
+	follower, target, err := i.followParticipants(actor, obj)
+	if err != nil {
+		return err
+	}
+	_, err = models.NewRelationships(i.db).AuthorizeFollow(target, follower)
+	return err
+}
+
+func (i *inboxProcessor) processReject(actor string, obj map[string]any) error {
+	typ := stringFromAny(obj["type"])
+	switch typ {
+	case "Follow":
+		return i.processRejectFollow(actor, obj)
+	default:
+		return fmt.Errorf("unknown reject object type: %q", typ)
+	}
+}
+
+// processRejectFollow records that the remote actor has rejected the local
+// actor's follow request, or removed them as a follower.
+func (i *inboxProcessor) processRejectFollow(actor string, obj map[string]any) error {
+	follower, target, err := i.followParticipants(actor, obj)
+	if err != nil {
+		return err
+	}
+	_, err = models.NewRelationships(i.db).RejectFollow(target, follower)
+	return err
+}
+
+// followParticipants returns the follower and the target of the follow
+// being accepted or rejected by the actor. The target must be the actor.
+func (i *inboxProcessor) followParticipants(actor string, follow map[string]any) (*models.Actor, *models.Actor, error) {
+	if object := stringFromAny(follow["object"]); object != actor {
+		return nil, nil, fmt.Errorf("follow of %q cannot be answered by %q", object, actor)
+	}
+	actors := models.NewActors(i.db)
+	follower, err := actors.FindByURI(stringFromAny(follow["actor"]))
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := actors.FindByURI(actor)
+	if err != nil {
+		return nil, nil, err
+	}
+	return follower, target, nil
 }
 
 func (i *inboxProcessor) processFollow(act map[string]any) error {
@@ -356,51 +709,71 @@ func (i *inboxProcessor) processFollow(act map[string]any) error {
 	if err != nil {
 		return err
 	}
-	relationships := models.NewRelationships(i.db)
-	_, err = relationships.Follow(actor, target)
+	_, err = models.NewRelationships(i.db).ReceiveFollow(actor, target, stringFromAny(act["id"]))
 	return err
 }
 
-func (i *inboxProcessor) processUpdate(update map[string]any) error {
+// processUpdate stores the updated object, which must be the actor or attributed to them.
+func (i *inboxProcessor) processUpdate(actor string, update map[string]any) error {
+	if stringFromAny(update["id"]) != actor {
+		if err := checkAttribution(actor, update); err != nil {
+			return err
+		}
+	}
 	return i.createObject(update)
 }
 
-func objToStatusPoll(obj map[string]any) (*models.StatusPoll, error) {
-	expiresAt, err := timeFromAny(obj["endTime"])
-	if err != nil {
-		return nil, err
+// checkAttribution checks that the object is attributed to the actor, and that
+// its id has the same origin as the actor's, so that an actor cannot create or
+// replace the objects of others.
+func checkAttribution(actor string, obj map[string]any) error {
+	if attributedTo := stringFromAny(obj["attributedTo"]); attributedTo != actor {
+		return fmt.Errorf("%v is attributed to %q, not %s", obj["id"], attributedTo, actor)
 	}
+	return checkOrigin(actor, stringFromAny(obj["id"]))
+}
 
-	poll := &models.StatusPoll{
-		ExpiresAt: expiresAt,
-		Multiple:  false,
+// checkOrigin checks that the id has the same scheme and host as the actor.
+func checkOrigin(actor, id string) error {
+	if !sameOrigin(actor, id) {
+		return fmt.Errorf("%q does not have the same origin as %s", id, actor)
 	}
+	return nil
+}
 
-	oneOf := anyToSlice(obj["oneOf"])
-	for _, o := range oneOf {
-		option := mapFromAny(o)
-		if option["type"] != "Note" {
-			return nil, fmt.Errorf("invalid poll option type: %q", option["type"])
-		}
-
-		poll.Options = append(poll.Options, models.StatusPollOption{
-			Title: stringFromAny(option["name"]),
-			Count: intFromAny(mapFromAny(option["replies"])["totalItems"]),
-		})
+// sameOrigin reports whether the URIs have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil || ua.Host == "" {
+		return false
 	}
-
-	return poll, nil
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && strings.EqualFold(ua.Host, ub.Host)
 }
 
-func (i *inboxProcessor) processDelete(act map[string]any) error {
+// processDelete deletes the actor, or one of their objects.
+func (i *inboxProcessor) processDelete(actor string, act map[string]any) error {
 	obj, ok := act["object"]
 	if !ok {
 		return errors.New("delete: missing object")
 	}
 	switch obj := obj.(type) {
 	case map[string]any:
-		return i.processDeleteStatus(stringFromAny(obj["id"]))
+		id := stringFromAny(obj["id"])
+		if err := checkOrigin(actor, id); err != nil {
+			return err
+		}
+		return i.processDeleteStatus(id)
 	case string:
+		if obj != actor {
+			if err := checkOrigin(actor, obj); err != nil {
+				return err
+			}
+			return i.processDeleteStatus(obj)
+		}
 		return i.processDeleteActor(obj)
 	default:
 		return fmt.Errorf("unknown delete object type: %q: %v", obj, act)
@@ -408,9 +781,6 @@ func (i *inboxProcessor) processDelete(act map[string]any) error {
 }
 
 func (i *inboxProcessor) processDeleteStatus(uri string) error {
-	if err := i.validateSignature(); err != nil {
-		return httpx.Error(http.StatusUnauthorized, err)
-	}
 	var obj []models.Object
 	if err := i.db.Where("uri = ?", uri).Find(&obj).Error; err != nil {
 		return err
@@ -432,33 +802,69 @@ func (i *inboxProcessor) processDeleteActor(uri string) error {
 		// already deleted
 		return nil
 	}
-	if err := i.validateSignature(); err != nil {
-		return httpx.Error(http.StatusUnauthorized, err)
-	}
 	return i.db.Delete(&obj[0]).Error
 }
 
-func (i *inboxProcessor) validateSignature() error {
-	verifier, err := httpsig.NewVerifier(i.req)
-	if err != nil {
-		return err
-	}
-	pubKey, err := i.getKey(verifier.KeyId())
+// errDomainBlocked is returned when a request is signed by a key on a blocked domain.
+var errDomainBlocked = errors.New("domain is blocked")
+
+// validateSignature verifies the HTTP signature of the request against the
+// public key of the signing actor, returning the signing actor. Keys on blocked
+// domains are refused before they are fetched.
+func validateSignature(db *gorm.DB, r *http.Request) (*models.Actor, error) {
+	var signer *models.Actor
+	_, err := httpsig.Verify(r, func(keyID string, refresh bool) (crypto.PublicKey, error) {
+		u, err := url.Parse(keyID)
+		if err != nil {
+			return nil, err
+		}
+		blocked, err := models.NewDomainBlocks(db).IsBlocked(u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, fmt.Errorf("%w: %s", errDomainBlocked, u.Hostname())
+		}
+		actor, pubKey, err := getKey(db, keyID, refresh)
+		signer = actor
+		return pubKey, err
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := verifier.Verify(pubKey, httpsig.RSA_SHA256); err != nil {
-		return err
+	return signer, nil
+}
+
+// signatureError returns the error for a request whose signature could not be
+// validated. Requests signed by a blocked domain are forbidden.
+func signatureError(err error) error {
+	if errors.Is(err, errDomainBlocked) {
+		return httpx.Error(http.StatusForbidden, err)
 	}
-	return nil
+	return httpx.Error(http.StatusUnauthorized, err)
 }
 
-func (i *inboxProcessor) getKey(keyID string) (crypto.PublicKey, error) {
-	actor, err := models.NewActors(i.db).FindOrCreateByURI(trimKeyId(keyID))
+// keyRefetchCooldown is how recently an actor must have been fetched for a request
+// to not fetch it again to find a rotated key. Without it, every badly signed
+// request would make us fetch the actor it claims to be from.
+const keyRefetchCooldown = 5 * time.Minute
+
+// getKey returns the actor who owns the key, and the key itself. If refresh is
+// true, and the actor was not fetched within keyRefetchCooldown, the actor is
+// fetched again so that a rotated key is found.
+func getKey(db *gorm.DB, keyID string, refresh bool) (*models.Actor, crypto.PublicKey, error) {
+	actors := models.NewActors(db)
+	actor, err := actors.FindOrCreateByURI(trimKeyId(keyID))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return pemToPublicKey(actor.PublicKey())
+	if refresh && time.Since(actor.UpdatedAt) > keyRefetchCooldown {
+		if actor, err = actors.Fetch(actor.URI()); err != nil {
+			return nil, nil, err
+		}
+	}
+	pubKey, err := actor.PublicKeyFor(keyID)
+	return actor, pubKey, err
 }
 
 func visiblity(obj map[string]any) string {
@@ -480,19 +886,6 @@ func visiblity(obj map[string]any) string {
 	return "direct" // hack
 }
 
-func pemToPublicKey(key []byte) (crypto.PublicKey, error) {
-	block, _ := pem.Decode(key)
-	if block.Type != "PUBLIC KEY" {
-		return nil, fmt.Errorf("pemToPublicKey: invalid pem type: %s", block.Type)
-	}
-	var publicKey interface{}
-	var err error
-	if publicKey, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
-		return nil, fmt.Errorf("pemToPublicKey: parsepkixpublickey: %w", err)
-	}
-	return publicKey, nil
-}
-
 // trimKeyId removes the #main-key suffix from the key id.
 func trimKeyId(id string) string {
 	if i := strings.Index(id, "#"); i != -1 {
@@ -1,12 +1,267 @@
 package activitypub
 
 import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/davecheney/pub/internal/httpsig"
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/models"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
 )
 
+func TestInboxCreate(t *testing.T) {
+	db := setupTestDB(t)
+	signer := newTestSigner(t)
+	inbox := NewInbox(MinSeenActivityTTL)
+
+	// mockSigner creates a remote actor whose key is the signer's.
+	mockSigner := func(t *testing.T, tx *gorm.DB, id string) *models.Actor {
+		der, err := x509.MarshalPKIXPublicKey(&signer.key.PublicKey)
+		require.NoError(t, err)
+		pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		return mockActor(t, tx, id, map[string]any{
+			"publicKey": map[string]any{
+				"id":           id + "#main-key",
+				"owner":        id,
+				"publicKeyPem": string(pub),
+			},
+		})
+	}
+	// post delivers the activity, signed with the actor's key, to the inbox.
+	post := func(t *testing.T, tx *gorm.DB, scheme httpsig.Scheme, actor *models.Actor, act map[string]any) error {
+		body, err := json.Marshal(act)
+		require.NoError(t, err)
+		r := httptest.NewRequest("POST", "https://example.com/inbox", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/activity+json")
+		require.NoError(t, httpsig.Sign(r, scheme, actor.URI()+"#main-key", signer.key, body))
+		env := &Env{DB: tx, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+		w := httptest.NewRecorder()
+		if err := inbox.Create(env, w, r); err != nil {
+			return err
+		}
+		require.Equal(t, http.StatusAccepted, w.Code)
+		return nil
+	}
+	follow := func(actor *models.Actor, n int) map[string]any {
+		return map[string]any{
+			"id":     fmt.Sprintf("%s#follows/%d", actor.URI(), n),
+			"type":   "Follow",
+			"actor":  actor.URI(),
+			"object": "https://example.com/u/alice",
+		}
+	}
+	queued := func(t *testing.T, tx *gorm.DB) int64 {
+		var count int64
+		require.NoError(t, tx.Model(&models.ActivitypubInboxRequest{}).Count(&count).Error)
+		return count
+	}
+	status := func(err error) int {
+		var se *httpx.StatusError
+		if !errors.As(err, &se) {
+			return 0
+		}
+		return se.Status()
+	}
+
+	for _, scheme := range []httpsig.Scheme{httpsig.Cavage, httpsig.RFC9421} {
+		t.Run(scheme.String(), func(t *testing.T) {
+			t.Run("signed activities are queued once", func(t *testing.T) {
+				require := require.New(t)
+				tx := db.Begin()
+				defer tx.Rollback()
+
+				bob := mockSigner(t, tx, "https://remote.example/users/bob")
+				require.NoError(post(t, tx, scheme, bob, follow(bob, 1)))
+				require.EqualValues(1, queued(t, tx))
+
+				// a replay is accepted, and discarded.
+				require.NoError(post(t, tx, scheme, bob, follow(bob, 1)))
+				require.EqualValues(1, queued(t, tx))
+			})
+
+			t.Run("activities of another actor are refused", func(t *testing.T) {
+				require := require.New(t)
+				tx := db.Begin()
+				defer tx.Rollback()
+
+				bob := mockSigner(t, tx, "https://remote.example/users/bob")
+				carol := mockActor(t, tx, "https://remote.example/users/carol", nil)
+				require.Equal(http.StatusUnauthorized, status(post(t, tx, scheme, bob, follow(carol, 1))))
+				require.Zero(queued(t, tx))
+			})
+
+			t.Run("activities from blocked domains are refused", func(t *testing.T) {
+				require := require.New(t)
+				tx := db.Begin()
+				defer tx.Rollback()
+
+				bob := mockSigner(t, tx, "https://remote.example/users/bob")
+				_, err := models.NewDomainBlocks(tx).Create("remote.example", "spam")
+				require.NoError(err)
+				require.Equal(http.StatusForbidden, status(post(t, tx, scheme, bob, follow(bob, 1))))
+				require.Zero(queued(t, tx))
+			})
+		})
+	}
+}
+
+func TestGetKey(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("a recently fetched actor is not fetched again", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		bob := mockActor(t, tx, "https://remote.example/users/bob", nil)
+		require.NoError(tx.Model(bob).UpdateColumn("updated_at", time.Now()).Error)
+
+		// there is no client to fetch bob with, so fetching again would fail.
+		actor, _, err := getKey(tx, bob.URI()+"#main-key", true)
+		require.Equal(bob.ObjectID, actor.ObjectID)
+		require.ErrorContains(err, "has no key")
+	})
+
+	t.Run("a stale actor is fetched again", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		bob := mockActor(t, tx, "https://remote.example/users/bob", nil)
+		_, _, err := getKey(tx, bob.URI()+"#main-key", true)
+		require.ErrorContains(err, "no activitypub client in context")
+	})
+}
+
+func TestProcessAccept(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("an accept may refer to the follow by its id", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := mockActor(t, tx, "https://example.com/u/alice", nil)
+		require.NoError(tx.Model(alice).UpdateColumn("type", "LocalPerson").Error)
+		bob := mockActor(t, tx, "https://remote.example/users/bob", nil)
+		relationships := models.NewRelationships(tx)
+		_, err := relationships.RequestFollow(alice, bob)
+		require.NoError(err)
+		var follow models.Relationship
+		require.NoError(tx.Take(&follow, "actor_id = ? and target_id = ?", alice.ObjectID, bob.ObjectID).Error)
+		require.NotEmpty(follow.FollowURI)
+
+		processor := &inboxProcessor{logger: slog.Default(), db: tx}
+		require.NoError(processor.processActivity(map[string]any{
+			"id":     "https://remote.example/users/bob#accepts/follows/1",
+			"type":   "Accept",
+			"actor":  bob.URI(),
+			"object": follow.FollowURI,
+		}))
+		require.NoError(tx.Take(&follow, "actor_id = ? and target_id = ?", alice.ObjectID, bob.ObjectID).Error)
+		require.True(follow.Following)
+		require.False(follow.Requested)
+
+		// an unknown follow is an error.
+		err = processor.processActivity(map[string]any{
+			"id":     "https://remote.example/users/bob#accepts/follows/2",
+			"type":   "Accept",
+			"actor":  bob.URI(),
+			"object": "https://example.com/u/alice#follows/1",
+		})
+		require.ErrorContains(err, "unknown object")
+	})
+}
+
+func TestProcessBlock(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("blocks of unknown or remote actors are ignored", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		bob := mockActor(t, tx, "https://remote.example/users/bob", nil)
+		carol := mockActor(t, tx, "https://other.example/users/carol", nil)
+
+		processor := &inboxProcessor{logger: slog.Default(), db: tx}
+		for _, object := range []string{"https://unknown.example/users/dave", carol.URI()} {
+			require.NoError(processor.processActivity(map[string]any{
+				"id":     "https://remote.example/users/bob#blocks/1",
+				"type":   "Block",
+				"actor":  bob.URI(),
+				"object": object,
+			}))
+		}
+		var count int64
+		require.NoError(tx.Model(&models.Relationship{}).Where("actor_id = ?", bob.ObjectID).Count(&count).Error)
+		require.EqualValues(0, count)
+	})
+}
+
+func TestProcessUndoAnnounce(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("only the announcer can undo an announce", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := mockActor(t, tx, "https://remote.example/users/alice", nil)
+		bob := mockActor(t, tx, "https://remote.example/users/bob", nil)
+		carol := mockActor(t, tx, "https://remote.example/users/carol", nil)
+		note := &models.Object{Properties: map[string]any{
+			"id":           "https://remote.example/users/alice/statuses/1",
+			"type":         "Note",
+			"attributedTo": alice.URI(),
+			"content":      "Wake me up before you go-go",
+			"published":    time.Now().Format(time.RFC3339),
+		}}
+		require.NoError(tx.Create(note).Error)
+		announce := map[string]any{
+			"id":        "https://remote.example/users/bob/statuses/2/activity",
+			"type":      "Announce",
+			"actor":     bob.URI(),
+			"object":    note.URI,
+			"published": time.Now().Format(time.RFC3339),
+		}
+		require.NoError(tx.Create(&models.Object{Properties: announce}).Error)
+
+		processor := &inboxProcessor{logger: slog.Default(), db: tx}
+		undo := func(actor *models.Actor) error {
+			return processor.processActivity(map[string]any{
+				"id":    actor.URI() + "#undos/1",
+				"type":  "Undo",
+				"actor": actor.URI(),
+				"object": map[string]any{
+					"id":     announce["id"],
+					"type":   "Announce",
+					"actor":  actor.URI(),
+					"object": note.URI,
+				},
+			})
+		}
+		require.ErrorContains(undo(carol), "cannot undo an announce")
+		require.NoError(tx.Where("uri = ?", announce["id"]).Take(&models.Object{}).Error)
+
+		require.NoError(undo(bob))
+		err := tx.Where("uri = ?", announce["id"]).Take(&models.Object{}).Error
+		require.ErrorIs(err, gorm.ErrRecordNotFound)
+	})
+}
+
 func TestPublishedAndUpdated(t *testing.T) {
 	t.Run("published and updated are the same when updated is missing ", func(t *testing.T) {
 		require := require.New(t)
@@ -54,3 +309,71 @@ func TestPublishedAndUpdated(t *testing.T) {
 		require.True(published.Before(updated))
 	})
 }
+
+func TestIsVote(t *testing.T) {
+	vote := map[string]any{
+		"type":      "Note",
+		"name":      "Tea",
+		"inReplyTo": "https://example.com/u/alice/statuses/1",
+	}
+	require.True(t, isVote(vote))
+
+	reply := map[string]any{
+		"type":      "Note",
+		"content":   "<p>Tea, obviously</p>",
+		"inReplyTo": "https://example.com/u/alice/statuses/1",
+	}
+	require.False(t, isVote(reply))
+}
+
+func TestCheckAttribution(t *testing.T) {
+	const bob = "https://example.org/users/bob"
+	require.NoError(t, checkAttribution(bob, map[string]any{
+		"id":           "https://example.org/users/bob/statuses/1",
+		"attributedTo": bob,
+	}))
+	// attributed to another actor
+	require.Error(t, checkAttribution(bob, map[string]any{
+		"id":           "https://example.org/users/carol/statuses/1",
+		"attributedTo": "https://example.org/users/carol",
+	}))
+	// attributed to bob, but hosted elsewhere
+	require.Error(t, checkAttribution(bob, map[string]any{
+		"id":           "https://example.com/u/alice/statuses/1",
+		"attributedTo": bob,
+	}))
+	require.Error(t, checkAttribution(bob, map[string]any{
+		"attributedTo": bob,
+	}))
+}
+
+func TestSameOrigin(t *testing.T) {
+	require.True(t, sameOrigin("https://example.org/users/bob", "https://EXAMPLE.org/users/bob#main-key"))
+	require.False(t, sameOrigin("https://example.org/users/bob", "http://example.org/users/bob"))
+	require.False(t, sameOrigin("https://example.org/users/bob", "https://example.org.evil/users/bob"))
+	require.False(t, sameOrigin("https://example.org:8443/users/bob", "https://example.org/users/bob"))
+	require.False(t, sameOrigin("", ""))
+}
+
+func TestCollectionItems(t *testing.T) {
+	items, ok := collectionItems(map[string]any{
+		"type":         "OrderedCollectionPage",
+		"orderedItems": []any{"https://example.com/1", "https://example.com/2"},
+	})
+	require.True(t, ok)
+	require.Len(t, items, 2)
+
+	items, ok = collectionItems(map[string]any{
+		"type":  "CollectionPage",
+		"items": []any{map[string]any{"type": "Note"}},
+	})
+	require.True(t, ok)
+	require.Len(t, items, 1)
+
+	// a collection whose items are paged
+	_, ok = collectionItems(map[string]any{
+		"type":  "Collection",
+		"first": "https://example.com/replies?page=true",
+	})
+	require.False(t, ok)
+}
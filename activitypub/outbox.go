@@ -5,9 +5,11 @@ import (
 	"net/http"
 
 	"github.com/davecheney/pub/internal/algorithms"
+	"github.com/davecheney/pub/internal/snowflake"
 	"github.com/davecheney/pub/internal/to"
 	"github.com/davecheney/pub/models"
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 )
 
 func Outbox(env *Env, w http.ResponseWriter, r *http.Request) error {
@@ -22,6 +24,7 @@ func Outbox(env *Env, w http.ResponseWriter, r *http.Request) error {
 func outboxIndex(env *Env, w http.ResponseWriter, r *http.Request) error {
 	var count int64
 	query := env.DB.Joins("JOIN actors ON actors.object_id = statuses.actor_id and actors.name = ? and actors.domain = ?", chi.URLParam(r, "name"), r.Host)
+	query = query.Where("statuses.visibility IN ?", outboxVisibilities)
 	if err := query.Model(&models.Status{}).Count(&count).Error; err != nil {
 		return err
 	}
@@ -60,45 +63,84 @@ func outboxShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 	}
 	var statuses []*models.Status
 	query := env.DB.Joins("JOIN actors ON actors.object_id = statuses.actor_id and actors.name = ? and actors.domain = ?", chi.URLParam(r, "name"), r.Host)
+	query = query.Where("statuses.visibility IN ?", outboxVisibilities)
 	query = query.Scopes(models.PaginateStatuses(r), models.PreloadStatus)
 	if err := query.Find(&statuses).Error; err != nil {
 		return err
 	}
+	objects, err := statusObjects(env.DB, statuses)
+	if err != nil {
+		return err
+	}
 	if len(statuses) > 0 {
 		resp["next"] = fmt.Sprintf("https://%s%s?max_id=%d&page=true", r.Host, r.URL.Path, statuses[0].ObjectID)
 		resp["prev"] = fmt.Sprintf("https://%s%s?min_id=%d&page=true", r.Host, r.URL.Path, statuses[len(statuses)-1].ObjectID)
 	}
-	resp["orderedItems"] = algorithms.Map(statuses, statusToItem)
+	resp["orderedItems"] = algorithms.Map(statuses, func(s *models.Status) *Item {
+		return statusToItem(s, objects[s.ObjectID])
+	})
 	return to.JSON(w, resp)
 }
 
-func statusToItem(s *models.Status) *Item {
+// outboxVisibilities are the visibilities of the statuses listed in an actor's outbox.
+var outboxVisibilities = []models.Visibility{"public", "unlisted"}
+
+// statusObjects returns the stored ActivityPub objects of the statuses which are not
+// reblogs, keyed by status id.
+func statusObjects(db *gorm.DB, statuses []*models.Status) (map[snowflake.ID]map[string]any, error) {
+	var ids []snowflake.ID
+	for _, s := range statuses {
+		if s.ReblogID == nil {
+			ids = append(ids, s.ObjectID)
+		}
+	}
+	objects := make(map[snowflake.ID]map[string]any, len(ids))
+	if len(ids) == 0 {
+		return objects, nil
+	}
+	var objs []*models.Object
+	if err := db.Where("id IN ?", ids).Find(&objs).Error; err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		objects[obj.ID] = obj.Properties
+	}
+	return objects, nil
+}
+
+// statusToItem returns the activity which published the status; an Announce for
+// a reblog, otherwise a Create embedding the status' object.
+func statusToItem(s *models.Status, obj map[string]any) *Item {
+	if s.ReblogID != nil {
+		return &Item{
+			ID:        s.URI(),
+			Type:      "Announce",
+			Actor:     s.Actor.URI(),
+			Published: s.ObjectID.ToTime().Format("2006-01-02T15:04:05Z"),
+			To:        statusTo(s),
+			CC:        statusCC(s),
+			Object:    s.Reblog.URI(),
+		}
+	}
 	return &Item{
-		ID:        s.URI(),
-		Type:      statusType(s),
+		ID:        s.URI() + "/activity",
+		Type:      "Create",
 		Actor:     s.Actor.URI(),
 		Published: s.ObjectID.ToTime().Format("2006-01-02T15:04:05Z"),
-		To:        statusTo(s),
-		CC:        statusCC(s),
-		Object:    statusToObject(s),
+		To:        obj["to"],
+		CC:        obj["cc"],
+		Object:    obj,
 	}
 }
 
 type Item struct {
-	ID        string   `json:"id"`
-	Type      string   `json:"type"`
-	Actor     string   `json:"actor"`
-	Published string   `json:"published"`
-	To        []string `json:"to"`
-	CC        []string `json:"cc"`
-	Object    any      `json:"object"`
-}
-
-func statusType(s *models.Status) string {
-	if s.ReblogID != nil {
-		return "Announce"
-	}
-	return "Create"
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Actor     string `json:"actor"`
+	Published string `json:"published"`
+	To        any    `json:"to"`
+	CC        any    `json:"cc"`
+	Object    any    `json:"object"`
 }
 
 func statusTo(s *models.Status) []string {
@@ -117,10 +159,3 @@ func statusCC(s *models.Status) []string {
 	}
 	return []string{}
 }
-
-func statusToObject(s *models.Status) any {
-	if s.ReblogID != nil {
-		return s.Reblog.URI()
-	}
-	return nil
-}
@@ -0,0 +1,145 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// FetchReplies walks the replies collections of the status and its descendants,
+// breadth first, to at most depth replies below the status, ingesting at most
+// limit replies. Replies which cannot be ingested are logged and skipped.
+func FetchReplies(ctx context.Context, logger *slog.Logger, db *gorm.DB, client *activitypub.Client, status *models.Status, depth, limit int) error {
+	processor := &inboxProcessor{
+		logger: logger,
+		db:     db.WithContext(activitypub.WithClient(ctx, client)),
+		client: client,
+	}
+	type reply struct {
+		uri   string
+		depth int
+	}
+	queue := []reply{{uri: status.URI()}}
+	seen := map[string]bool{status.URI(): true}
+	fetched := 0
+	for len(queue) > 0 && fetched < limit {
+		next := queue[0]
+		queue = queue[1:]
+		if next.depth == depth {
+			continue
+		}
+		var obj models.Object
+		if err := processor.db.Where("uri = ?", next.uri).Take(&obj).Error; err != nil {
+			logger.Info("fetchReplies", "status", next.uri, "error", err)
+			continue
+		}
+		uris, err := processor.fetchReplies(next.uri, obj.Properties["replies"], limit-fetched)
+		if err != nil {
+			// the rest of the thread may still be reachable.
+			logger.Info("fetchReplies", "status", next.uri, "error", err)
+		}
+		for _, uri := range uris {
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			fetched++
+			queue = append(queue, reply{uri: uri, depth: next.depth + 1})
+		}
+	}
+	return nil
+}
+
+// fetchReplies ingests up to limit items from the replies collection of the status
+// with the given URI, returning the URIs of the replies ingested.
+func (i *inboxProcessor) fetchReplies(uri string, replies any, limit int) ([]string, error) {
+	// origin is the URI the current page was fetched from, or that of the document
+	// embedding it. Only replies embedded by their own server are trusted.
+	origin := linkOr(replies, uri)
+	collection, err := i.dereference(replies)
+	if err != nil || collection == nil {
+		return nil, err
+	}
+	page := collection
+	if _, ok := collectionItems(collection); !ok {
+		// items are paged.
+		origin = linkOr(collection["first"], origin)
+		if page, err = i.dereference(collection["first"]); err != nil {
+			return nil, err
+		}
+	}
+	var uris []string
+	for pages := 1; page != nil; pages++ {
+		items, _ := collectionItems(page)
+		for _, item := range items {
+			if len(uris) == limit {
+				break
+			}
+			uri, err := i.ingestReply(origin, item)
+			if err != nil {
+				i.logger.Info("fetchReplies", "item", item, "error", err)
+				continue
+			}
+			uris = append(uris, uri)
+		}
+		if len(uris) == limit || pages == maxCollectionPages {
+			break
+		}
+		origin = linkOr(page["next"], origin)
+		if page, err = i.dereference(page["next"]); err != nil {
+			return uris, err
+		}
+	}
+	return uris, nil
+}
+
+// linkOr returns v if it is a link, otherwise uri.
+func linkOr(v any, uri string) string {
+	if link, ok := v.(string); ok {
+		return link
+	}
+	return uri
+}
+
+// ingestReply creates the status for the reply, which is either a link or an embedded Note,
+// and returns its URI. An embedded Note from another origin than the page embedding it
+// is fetched from its own server, rather than taken on trust.
+func (i *inboxProcessor) ingestReply(origin string, item any) (string, error) {
+	switch item := item.(type) {
+	case string:
+		status, err := models.NewStatuses(i.db).FindOrCreateByURI(item)
+		if err != nil {
+			return "", err
+		}
+		return status.URI(), nil
+	case map[string]any:
+		switch item["type"] {
+		case "Note", "Question":
+			id := stringFromAny(item["id"])
+			if !sameOrigin(origin, id) {
+				return i.ingestReply(origin, id)
+			}
+			return id, i.createObject(item)
+		default:
+			return "", fmt.Errorf("unsupported reply type %q", item["type"])
+		}
+	default:
+		return "", fmt.Errorf("unsupported reply %v", item)
+	}
+}
+
+// collectionItems returns the items of a collection, or collection page, which
+// may be ordered or unordered.
+func collectionItems(collection map[string]any) ([]any, bool) {
+	if items, ok := collection["orderedItems"]; ok {
+		return anyToSlice(items), true
+	}
+	if items, ok := collection["items"]; ok {
+		return anyToSlice(items), true
+	}
+	return nil, false
+}
@@ -0,0 +1,113 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+func TestFetchReplies(t *testing.T) {
+	db := setupTestDB(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := activitypub.NewClient(newTestSigner(t))
+	require.NoError(t, err)
+
+	note := func(id, author, inReplyTo, content string) map[string]any {
+		obj := map[string]any{
+			"id":           id,
+			"type":         "Note",
+			"attributedTo": author,
+			"published":    time.Now().UTC().Format(time.RFC3339),
+			"content":      content,
+			"to":           []any{"https://www.w3.org/ns/activitystreams#Public"},
+		}
+		if inReplyTo != "" {
+			obj["inReplyTo"] = inReplyTo
+		}
+		return obj
+	}
+	// mockStatus creates the status with the given replies collection.
+	mockStatus := func(t *testing.T, tx *gorm.DB, id, author string, replies any) *models.Status {
+		obj := note(id, author, "", "<p>hello</p>")
+		obj["replies"] = replies
+		require.NoError(t, tx.Create(&models.Object{Properties: obj}).Error)
+		status, err := models.NewStatuses(tx).FindByURI(id)
+		require.NoError(t, err)
+		return status
+	}
+	content := func(t *testing.T, tx *gorm.DB, uri string) string {
+		var obj models.Object
+		require.NoError(t, tx.Where("uri = ?", uri).Take(&obj).Error)
+		return stringFromAny(obj.Properties["content"])
+	}
+
+	t.Run("embedded replies from another origin are fetched from it", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/activity+json")
+			json.NewEncoder(w).Encode(note("http://"+r.Host+r.URL.Path, "http://"+r.Host+"/users/carol", "", "<p>genuine</p>"))
+		}))
+		defer other.Close()
+		carol := mockActor(t, tx, other.URL+"/users/carol", nil)
+
+		bob := mockActor(t, tx, "https://remote.example/users/bob", nil)
+		status := mockStatus(t, tx, bob.URI()+"/statuses/1", bob.URI(), map[string]any{
+			"id":   bob.URI() + "/statuses/1/replies",
+			"type": "Collection",
+			"first": map[string]any{
+				"type": "CollectionPage",
+				"items": []any{
+					note(bob.URI()+"/statuses/2", bob.URI(), bob.URI()+"/statuses/1", "<p>self reply</p>"),
+					note(carol.URI()+"/statuses/3", carol.URI(), bob.URI()+"/statuses/1", "<p>forged</p>"),
+				},
+			},
+		})
+
+		require.NoError(FetchReplies(context.Background(), logger, tx, client, status, 1, 10))
+		require.Equal("<p>self reply</p>", content(t, tx, bob.URI()+"/statuses/2"))
+		require.Equal("<p>genuine</p>", content(t, tx, carol.URI()+"/statuses/3"))
+	})
+
+	t.Run("stops walking an endless collection", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		var pages int
+		var srv *httptest.Server
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// every page is empty and has a next page.
+			pages++
+			w.Header().Set("Content-Type", "application/activity+json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"type":  "CollectionPage",
+				"items": []any{},
+				"next":  fmt.Sprintf("%s/users/bob/statuses/1/replies?page=%d", srv.URL, pages+1),
+			})
+		}))
+		defer srv.Close()
+
+		bob := mockActor(t, tx, srv.URL+"/users/bob", nil)
+		status := mockStatus(t, tx, bob.URI()+"/statuses/1", bob.URI(), map[string]any{
+			"type":  "Collection",
+			"first": bob.URI() + "/statuses/1/replies?page=1",
+		})
+
+		require.NoError(FetchReplies(context.Background(), logger, tx, client, status, 1, 10))
+		require.Equal(maxCollectionPages, pages)
+	})
+}
@@ -0,0 +1,147 @@
+package activitypub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/davecheney/pub/activitypub/activities"
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/internal/snowflake"
+	"github.com/davecheney/pub/internal/to"
+	"github.com/davecheney/pub/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// StatusesShow serves the ActivityPub representation of a local status.
+// Deleted statuses are served as a Tombstone with a 410 Gone status.
+func StatusesShow(env *Env, w http.ResponseWriter, r *http.Request) error {
+	return statusShow(env, w, r, func(_ *models.Actor, obj map[string]any) map[string]any {
+		return withContext(obj)
+	})
+}
+
+// StatusesActivityShow serves the Create activity which wraps a local status.
+func StatusesActivityShow(env *Env, w http.ResponseWriter, r *http.Request) error {
+	return statusShow(env, w, r, activities.Create)
+}
+
+// statusShow serves the local status named in the request, rendered by fn.
+// Statuses which are not public or unlisted are only served to signed requests
+// from actors to whom the status was addressed, or, for followers only statuses,
+// the author's followers. Otherwise the status is reported as not found.
+func statusShow(env *Env, w http.ResponseWriter, r *http.Request, fn func(*models.Actor, map[string]any) map[string]any) error {
+	contentType, ok := negotiateContentType(r)
+	if !ok {
+		return httpx.Error(http.StatusNotAcceptable, fmt.Errorf("unsupported Accept header: %q", r.Header.Get("Accept")))
+	}
+	id, err := snowflake.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return httpx.Error(http.StatusNotFound, err)
+	}
+	var obj models.Object
+	if err := env.DB.Take(&obj, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return httpx.Error(http.StatusNotFound, err)
+		}
+		return err
+	}
+	if obj.URI != fmt.Sprintf("https://%s/u/%s/statuses/%d", r.Host, chi.URLParam(r, "name"), id) {
+		return httpx.Error(http.StatusNotFound, fmt.Errorf("status %d not found", id))
+	}
+	w.Header().Set("Content-Type", contentType)
+	switch obj.Type {
+	case "Tombstone":
+		w.WriteHeader(http.StatusGone)
+		return to.JSON(w, withContext(obj.Properties))
+	case "Note", "Question":
+		var status models.Status
+		if err := env.DB.Joins("Actor").Take(&status, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return httpx.Error(http.StatusNotFound, err)
+			}
+			return err
+		}
+		if err := canView(env, r, &status, obj.Properties); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusOK)
+		return to.JSON(w, fn(status.Actor, obj.Properties))
+	default:
+		return httpx.Error(http.StatusNotFound, fmt.Errorf("status %d not found", id))
+	}
+}
+
+// canView returns an error if the signer of the request, if any, may not view the status.
+func canView(env *Env, r *http.Request, status *models.Status, obj map[string]any) error {
+	switch status.Visibility {
+	case "public", "unlisted":
+		return nil
+	}
+	notFound := httpx.Error(http.StatusNotFound, fmt.Errorf("status %d not found", status.ObjectID))
+	if r.Header.Get("Signature") == "" {
+		return notFound
+	}
+	signer, err := validateSignature(env.DB, r)
+	if err != nil {
+		return signatureError(err)
+	}
+	for _, recipient := range append(anyToSlice(obj["to"]), anyToSlice(obj["cc"])...) {
+		if recipient == signer.URI() {
+			return nil
+		}
+	}
+	if status.Visibility == "private" {
+		var followers int64
+		if err := env.DB.Model(&models.Relationship{}).Where("actor_id = ? and target_id = ? and followed_by = true", status.ActorID, signer.ObjectID).Count(&followers).Error; err != nil {
+			return err
+		}
+		if followers > 0 {
+			return nil
+		}
+	}
+	return notFound
+}
+
+// negotiateContentType returns the ActivityStreams media type to serve in response
+// to the request's Accept header. If the client does not accept any ActivityStreams
+// media type, false is returned.
+func negotiateContentType(r *http.Request) (string, bool) {
+	const (
+		activityJSON = "application/activity+json; charset=utf-8"
+		ldJSON       = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"; charset=utf-8`
+	)
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return activityJSON, true
+	}
+	var types []string
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		types = append(types, strings.ToLower(strings.TrimSpace(mediaType)))
+	}
+	switch {
+	case slices.Contains(types, "application/activity+json"):
+		return activityJSON, true
+	case slices.Contains(types, "application/ld+json"):
+		return ldJSON, true
+	case slices.Contains(types, "application/json"), slices.Contains(types, "application/*"), slices.Contains(types, "*/*"):
+		return activityJSON, true
+	default:
+		return "", false
+	}
+}
+
+// withContext returns a copy of the object with the ActivityStreams @context added.
+func withContext(obj map[string]any) map[string]any {
+	m := map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+	}
+	for k, v := range obj {
+		m[k] = v
+	}
+	return m
+}
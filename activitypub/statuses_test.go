@@ -0,0 +1,32 @@
+package activitypub
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		ok          bool
+	}{
+		{"", "application/activity+json; charset=utf-8", true},
+		{"application/activity+json", "application/activity+json; charset=utf-8", true},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, `application/ld+json; profile="https://www.w3.org/ns/activitystreams"; charset=utf-8`, true},
+		{"application/json, text/plain", "application/activity+json; charset=utf-8", true},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "application/activity+json; charset=utf-8", true},
+		{"text/html", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "https://example.com/u/alice/statuses/1", nil)
+			r.Header.Set("Accept", tt.accept)
+			contentType, ok := negotiateContentType(r)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.contentType, contentType)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package activitypub
 import (
 	"net/http"
 
+	"github.com/davecheney/pub/internal/algorithms"
 	"github.com/davecheney/pub/internal/httpx"
 	"github.com/davecheney/pub/internal/to"
 	"github.com/davecheney/pub/models"
@@ -20,7 +21,14 @@ func UsersShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	return to.JSON(w, map[string]any{
+	return to.JSON(w, actorToObject(actor))
+}
+
+// actorToObject returns the ActivityPub representation of the local actor,
+// rendered from the actor's stored object.
+func actorToObject(actor *models.Actor) map[string]any {
+	props := actor.Object.Properties
+	obj := map[string]any{
 		"@context": []any{
 			"https://www.w3.org/ns/activitystreams",
 			"https://w3id.org/security/v1",
@@ -88,9 +96,9 @@ func UsersShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 		"outbox":                    actor.URI() + "/outbox",
 		"featured":                  actor.URI() + "/collections/featured",
 		"featuredTags":              actor.URI() + "/collections/tags",
-		"preferredUsername":         name,
-		"name":                      name,
-		"summary":                   "toot!", // actor.Note(),
+		"preferredUsername":         actor.Name,
+		"name":                      stringOrDefault(actor.DisplayName(), actor.Name),
+		"summary":                   actor.Note(),
 		"url":                       actor.URL(),
 		"manuallyApprovesFollowers": actor.Locked(),
 		"discoverable":              false,                                                  // mastodon sets this to false
@@ -101,20 +109,48 @@ func UsersShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 			"owner":        actor.URI(),
 			"publicKeyPem": string(actor.PublicKey()),
 		},
-		"tag":        []any{},
-		"attachment": []any{},
+		"tag": []any{},
+		"attachment": algorithms.Map(actor.Attributes(), func(a models.ActorAttachment) any {
+			return map[string]any{
+				"type":  a.Type,
+				"name":  a.Name,
+				"value": a.Value,
+			}
+		}),
 		"endpoints": map[string]any{
-			"sharedInbox": "https://" + r.Host + "/inbox",
+			"sharedInbox": "https://" + actor.Domain + "/inbox",
 		},
-		"icon": map[string]any{
+	}
+	if props.Icon.URL != "" {
+		obj["icon"] = map[string]any{
 			"type":      "Image",
-			"mediaType": "image/jpeg",
-			"url":       "https://media.hachyderm.io/accounts/headers/109/364/117/028/564/263/original/c6b5edf498087717.jpeg",
-		},
-		"image": map[string]any{
+			"mediaType": props.Icon.MediaType,
+			"url":       props.Icon.URL,
+		}
+	}
+	if props.Image.URL != "" {
+		obj["image"] = map[string]any{
 			"type":      "Image",
-			"mediaType": "image/jpeg",
-			"url":       "https://media.hachyderm.io/accounts/headers/109/364/117/028/564/263/original/c6b5edf498087717.jpeg",
-		},
-	})
+			"mediaType": props.Image.MediaType,
+			"url":       props.Image.URL,
+		}
+	}
+	if aliases := actor.AlsoKnownAs(); len(aliases) > 0 {
+		obj["alsoKnownAs"] = aliases
+	}
+	if movedTo := actor.MovedTo(); movedTo != "" {
+		obj["movedTo"] = movedTo
+	}
+	if keys := actor.AssertionMethod(); len(keys) > 0 {
+		obj["@context"] = append(obj["@context"].([]any), "https://w3id.org/security/multikey/v1")
+		obj["assertionMethod"] = keys
+	}
+	return obj
+}
+
+func stringOrDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/davecheney/pub/internal/snowflake"
 	"github.com/davecheney/pub/models"
 	"gorm.io/gorm"
 )
@@ -39,6 +40,46 @@ func (a *AutoMigrateCmd) Run(ctx *Context) error {
 	// 	return err
 	// }
 
+	// pre migration fixups
+
+	ctx.Logger.Info("dropping the single slot index on reaction requests")
+	if db.Migrator().HasIndex(&models.ReactionRequest{}, "uidx_reaction_requests_actor_id_target_id") {
+		if err := db.Migrator().DropIndex(&models.ReactionRequest{}, "uidx_reaction_requests_actor_id_target_id"); err != nil {
+			return err
+		}
+	}
+
+	// Reaction requests were once delivered to every recipient at once. They are
+	// queued again, one for each recipient, after the migration.
+	type pendingReaction struct {
+		ActorID  snowflake.ID
+		TargetID snowflake.ID
+		Action   models.ReactionRequestAction
+	}
+	var pendingReactions []pendingReaction
+	if db.Migrator().HasTable(&models.ReactionRequest{}) && !db.Migrator().HasColumn(&models.ReactionRequest{}, "RecipientID") {
+		ctx.Logger.Info("removing reaction requests queued before they had a recipient")
+		err = db.Model(&models.ReactionRequest{}).Select("actor_id, target_id, action").Find(&pendingReactions).Error
+		if err != nil {
+			return err
+		}
+		if err := db.Where("1 = 1").Delete(&models.ReactionRequest{}).Error; err != nil {
+			return err
+		}
+	}
+	if db.Migrator().HasIndex(&models.ReactionRequest{}, "uidx_reaction_requests_actor_id_target_id_kind") {
+		if err := db.Migrator().DropIndex(&models.ReactionRequest{}, "uidx_reaction_requests_actor_id_target_id_kind"); err != nil {
+			return err
+		}
+	}
+
+	ctx.Logger.Info("dropping the single slot index on relationship requests")
+	if db.Migrator().HasIndex(&models.RelationshipRequest{}, "uidx_relationship_requests_actor_id_target_id") {
+		if err := db.Migrator().DropIndex(&models.RelationshipRequest{}, "uidx_relationship_requests_actor_id_target_id"); err != nil {
+			return err
+		}
+	}
+
 	ctx.Logger.Info("apply migrations")
 	if err := db.AutoMigrate(models.AllTables()...); err != nil {
 		return err
@@ -53,5 +94,89 @@ func (a *AutoMigrateCmd) Run(ctx *Context) error {
 		return err
 	}
 
+	ctx.Logger.Info("converting local accounts to LocalPerson")
+	accounts := db.Model(&models.Account{}).Select("actor_id")
+	err = db.Model(&models.Actor{}).Where("type = ? and object_id in (?)", "Person", accounts).UpdateColumn("type", "LocalPerson").Error
+	if err != nil {
+		return err
+	}
+
+	// Accounts were once created with the publicKey id and owner of the admin
+	// actor. Actor documents are served with the account's own key id, but the
+	// stored key must match it for keys to be found by id.
+	ctx.Logger.Info("storing the keys of local actors under their own ids")
+	var local []*models.Actor
+	err = db.Scopes(models.PreloadActor).Where("type in ?", []string{"LocalPerson", "LocalService"}).Find(&local).Error
+	if err != nil {
+		return err
+	}
+	for _, actor := range local {
+		publicKey := actor.Object.Properties.PublicKey
+		if publicKey.ID == actor.PublicKeyID() && publicKey.Owner == actor.URI() {
+			continue
+		}
+		var obj models.Object
+		if err := db.Take(&obj, actor.ObjectID).Error; err != nil {
+			return err
+		}
+		obj.Properties["publicKey"] = map[string]any{
+			"id":           actor.PublicKeyID(),
+			"owner":        actor.URI(),
+			"publicKeyPem": publicKey.PublicKeyPem,
+		}
+		err = db.Model(&obj).Select("properties").UpdateColumns(&models.Object{Properties: obj.Properties}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Logger.Info("scheduling requests queued before next_attempt_at was set on creation")
+	for _, table := range []any{
+		&models.ActivitypubRefresh{}, &models.ActivitypubInboxRequest{}, &models.ActivitypubOutboxRequest{},
+		&models.ActorRefreshRequest{}, &models.ActorBackfillRequest{},
+		&models.ReactionRequest{}, &models.RelationshipRequest{}, &models.ReportRequest{},
+		&models.StatusPollVoteRequest{}, &models.StatusRepliesRequest{},
+	} {
+		err = db.Model(table).Where("next_attempt_at IS NULL").UpdateColumn("next_attempt_at", gorm.Expr("created_at")).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Logger.Info("setting the kind of pending reaction requests")
+	for kind, actions := range map[string][]string{
+		"like":   {"like", "unlike"},
+		"reblog": {"reblog", "unreblog"},
+		"pin":    {"pin", "unpin"},
+	} {
+		err = db.Model(&models.ReactionRequest{}).Where("kind = ? and action in ?", "", actions).UpdateColumn("kind", kind).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx.Logger.Info("queuing reaction requests for each recipient", "count", len(pendingReactions))
+	for _, pending := range pendingReactions {
+		var actor models.Actor
+		if err := db.Scopes(models.PreloadActor).Take(&actor, pending.ActorID).Error; err != nil {
+			return err
+		}
+		status, err := models.NewStatuses(db).FindByID(pending.TargetID)
+		if err != nil {
+			return err
+		}
+		if err := models.NewReactions(db).Requeue(&actor, status, pending.Action); err != nil {
+			return err
+		}
+	}
+
+	ctx.Logger.Info("setting the kind of pending relationship requests")
+	for _, action := range []models.RelationshipRequestAction{"follow", "unfollow", "block", "unblock", "accept", "reject"} {
+		err = db.Model(&models.RelationshipRequest{}).Where("kind = ? and action = ?", "", action).UpdateColumn("kind", action.Kind()).Error
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
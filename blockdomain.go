@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/davecheney/pub/models"
+	"gorm.io/gorm"
+)
+
+type BlockDomainCmd struct {
+	Domain  string `required:"" help:"domain to block, including its subdomains"`
+	Comment string `help:"reason for the block, shown publicly"`
+	Unblock bool   `help:"remove the block on the domain"`
+}
+
+func (b *BlockDomainCmd) Run(ctx *Context) error {
+	db, err := gorm.Open(ctx.Dialector, &ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	blocks := models.NewDomainBlocks(db)
+	if b.Unblock {
+		return blocks.Delete(b.Domain)
+	}
+	_, err = blocks.Create(b.Domain, b.Comment)
+	return err
+}
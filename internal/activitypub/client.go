@@ -1,12 +1,16 @@
 package activitypub
 
 import (
+	"bytes"
 	"context"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/carlmjohnson/requests"
 	"github.com/davecheney/pub/internal/httpsig"
@@ -30,8 +34,19 @@ func WithClient(ctx context.Context, c *Client) context.Context {
 // Client is an ActivityPub client which can be used to fetch remote
 // ActivityPub resources.
 type Client struct {
+	// keys returns the keys which sign each request.
+	keys func() (*signingKeys, error)
+}
+
+// signingKeys are the keys of a Signer.
+type signingKeys struct {
 	keyID      string
 	privateKey crypto.PrivateKey
+
+	// ed25519KeyID and ed25519Key are the signer's Ed25519 key, if it has one,
+	// which is preferred for RFC 9421 signatures.
+	ed25519KeyID string
+	ed25519Key   ed25519.PrivateKey
 }
 
 // Signer represents an object that can sign HTTP requests.
@@ -40,28 +55,65 @@ type Signer interface {
 	PrivKey() (*rsa.PrivateKey, error)
 }
 
-// NewClient returns a new ActivityPub client.
+// Ed25519Signer is a Signer which may also hold an Ed25519 key.
+// Ed25519PrivKey returns nil if the signer has no Ed25519 key.
+type Ed25519Signer interface {
+	Ed25519KeyID() string
+	Ed25519PrivKey() (ed25519.PrivateKey, error)
+}
+
+// NewClient returns a new ActivityPub client which signs with the keys
+// signAs has when the client is created.
 func NewClient(signAs Signer) (*Client, error) {
-	privateKey, err := signAs.PrivKey()
+	keys, err := keysOf(signAs)
 	if err != nil {
 		return nil, err
 	}
 	return &Client{
+		keys: func() (*signingKeys, error) { return keys, nil },
+	}, nil
+}
+
+// NewReloadingClient returns a new ActivityPub client which calls signer for
+// each request, so a long lived client signs with keys rotated after it was created.
+func NewReloadingClient(signer func() (Signer, error)) *Client {
+	return &Client{
+		keys: func() (*signingKeys, error) {
+			signAs, err := signer()
+			if err != nil {
+				return nil, err
+			}
+			return keysOf(signAs)
+		},
+	}
+}
+
+func keysOf(signAs Signer) (*signingKeys, error) {
+	privateKey, err := signAs.PrivKey()
+	if err != nil {
+		return nil, err
+	}
+	keys := &signingKeys{
 		keyID:      signAs.PublicKeyID(),
 		privateKey: privateKey,
-	}, nil
+	}
+	if signer, ok := signAs.(Ed25519Signer); ok {
+		key, err := signer.Ed25519PrivKey()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			keys.ed25519KeyID, keys.ed25519Key = signer.Ed25519KeyID(), key
+		}
+	}
+	return keys, nil
 }
 
 // Fetch fetches the ActivityPub resource at the given URL and decodes it into the given object.
 func (c *Client) Fetch(ctx context.Context, uri string, obj interface{}) error {
 	return requests.URL(uri).
 		Accept(`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`).
-		Transport(requests.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
-			if err := httpsig.Sign(req, c.keyID, c.privateKey, nil); err != nil {
-				return nil, fmt.Errorf("failed to sign request: %w", err)
-			}
-			return http.DefaultTransport.RoundTrip(req)
-		})).
+		Transport(c.signingTransport(nil)).
 		CheckContentType(
 			"application/ld+json",
 			"application/activity+json",
@@ -82,13 +134,72 @@ func (c *Client) Post(ctx context.Context, url string, obj map[string]any) error
 	return requests.URL(url).
 		BodyBytes(body).
 		Header("Content-Type", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`).
-		Transport(requests.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
-			if err := httpsig.Sign(req, c.keyID, c.privateKey, body); err != nil {
-				return nil, fmt.Errorf("failed to sign request: %w", err)
-			}
-			return http.DefaultTransport.RoundTrip(req)
-		})).
+		Transport(c.signingTransport(body)).
 		ToWriter(os.Stderr).
 		CheckStatus(http.StatusOK, http.StatusCreated, http.StatusAccepted).
 		Fetch(ctx)
 }
+
+// signingTransport returns a transport which signs each request with the scheme
+// last known to work for its host. If the server responds 401 Unauthorized, the
+// request is signed with the other scheme and sent once more.
+func (c *Client) signingTransport(body []byte) http.RoundTripper {
+	return requests.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		scheme := signatureSchemes.get(req.URL.Host)
+		resp, err := c.signAndSend(req, scheme, body)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		retry := req.Clone(req.Context())
+		if body != nil {
+			retry.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resp, err = c.signAndSend(retry, scheme.Other(), body)
+		if err == nil && resp.StatusCode != http.StatusUnauthorized {
+			signatureSchemes.set(req.URL.Host, scheme.Other())
+		}
+		return resp, err
+	})
+}
+
+func (c *Client) signAndSend(req *http.Request, scheme httpsig.Scheme, body []byte) (*http.Response, error) {
+	keys, err := c.keys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	keyID, privateKey := keys.keyID, keys.privateKey
+	if scheme == httpsig.RFC9421 && keys.ed25519Key != nil {
+		keyID, privateKey = keys.ed25519KeyID, keys.ed25519Key
+	}
+	if err := httpsig.Sign(req, scheme, keyID, privateKey, body); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// signatureSchemes records the signature scheme each remote host accepts.
+// It is shared by all clients, as the scheme a host accepts does not depend on the signer.
+var signatureSchemes = schemeCache{
+	schemes: make(map[string]httpsig.Scheme),
+}
+
+type schemeCache struct {
+	mu      sync.Mutex
+	schemes map[string]httpsig.Scheme
+}
+
+// get returns the scheme known to work for the host, or httpsig.Cavage if none is known.
+func (s *schemeCache) get(host string) httpsig.Scheme {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.schemes[host]
+}
+
+func (s *schemeCache) set(host string, scheme httpsig.Scheme) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemes[host] = scheme
+}
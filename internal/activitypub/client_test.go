@@ -0,0 +1,88 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/davecheney/pub/internal/httpsig"
+	"github.com/stretchr/testify/require"
+)
+
+type testSigner struct {
+	key *rsa.PrivateKey
+	id  string
+}
+
+func (s *testSigner) PublicKeyID() string {
+	if s.id != "" {
+		return s.id
+	}
+	return "https://example.com/u/admin#main-key"
+}
+
+func (s *testSigner) PrivKey() (*rsa.PrivateKey, error) { return s.key, nil }
+
+func TestClientFallsBackToOtherSignatureScheme(t *testing.T) {
+	require := require.New(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	client, err := NewClient(&testSigner{key: key})
+	require.NoError(err)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// this server only accepts RFC 9421 signatures.
+		if r.Header.Get("Signature-Input") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/activity+json")
+		w.Write([]byte(`{"id":"https://example.org/note"}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(err)
+
+	var obj map[string]any
+	require.NoError(client.Fetch(context.Background(), srv.URL, &obj))
+	require.Equal("https://example.org/note", obj["id"])
+	require.Equal(2, requests)
+	require.Equal(httpsig.RFC9421, signatureSchemes.get(u.Host))
+
+	// the working scheme is remembered, so the next request is accepted first time.
+	require.NoError(client.Fetch(context.Background(), srv.URL, &obj))
+	require.Equal(3, requests)
+}
+
+func TestReloadingClientSignsWithCurrentKeys(t *testing.T) {
+	require := require.New(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	signer := &testSigner{key: key}
+	client := NewReloadingClient(func() (Signer, error) { return signer, nil })
+
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("Signature")
+		w.Header().Set("Content-Type", "application/activity+json")
+		w.Write([]byte(`{"id":"https://example.org/note"}`))
+	}))
+	defer srv.Close()
+
+	var obj map[string]any
+	require.NoError(client.Fetch(context.Background(), srv.URL, &obj))
+	require.Contains(signature, `keyId="https://example.com/u/admin#main-key"`)
+
+	// the keys are rotated after the client was created.
+	key, err = rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	signer = &testSigner{key: key, id: "https://example.com/u/admin#main-key-2"}
+	require.NoError(client.Fetch(context.Background(), srv.URL, &obj))
+	require.Contains(signature, `keyId="https://example.com/u/admin#main-key-2"`)
+}
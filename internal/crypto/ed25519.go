@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// GenerateEd25519Keypair returns a new Ed25519 keypair. The private key is
+// PKCS #8 encoded, the public key PKIX encoded.
+func GenerateEd25519Keypair() (*Keypair, error) {
+	publickey, privatekey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privatekey)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publickey)
+	if err != nil {
+		return nil, err
+	}
+	return &Keypair{
+		PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}),
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes}),
+	}, nil
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded Ed25519 private key.
+func ParseEd25519PrivateKey(pemBytes []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("expected PRIVATE KEY")
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsedKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected ed25519.PrivateKey, got %T", parsedKey)
+	}
+	return privateKey, nil
+}
+
+// ParsePublicKey parses a PEM encoded PKIX, or PKCS #1 RSA, public key.
+func ParsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("expected PEM encoded public key")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("invalid pem type: %s", block.Type)
+	}
+}
@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// multicodec prefixes of the public key types which may be encoded as a Multikey.
+// https://github.com/multiformats/multicodec/blob/master/table.csv
+const (
+	multicodecEd25519 = 0xed
+	multicodecRSA     = 0x1205
+)
+
+// EncodeMultikey returns the publicKeyMultibase encoding of the public key, as
+// used by the Multikey type of FEP-521a.
+func EncodeMultikey(pub crypto.PublicKey) (string, error) {
+	var codec uint64
+	var key []byte
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		codec, key = multicodecEd25519, pub
+	case *rsa.PublicKey:
+		codec, key = multicodecRSA, x509.MarshalPKCS1PublicKey(pub)
+	default:
+		return "", fmt.Errorf("unsupported public key type: %T", pub)
+	}
+	b := binary.AppendUvarint(nil, codec)
+	return "z" + base58Encode(append(b, key...)), nil
+}
+
+// DecodeMultikey decodes a publicKeyMultibase encoded public key.
+func DecodeMultikey(s string) (crypto.PublicKey, error) {
+	if !strings.HasPrefix(s, "z") {
+		return nil, errors.New("multikey is not base58btc encoded")
+	}
+	b, err := base58Decode(s[1:])
+	if err != nil {
+		return nil, err
+	}
+	codec, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, errors.New("multikey has no multicodec prefix")
+	}
+	key := b[n:]
+	switch codec {
+	case multicodecEd25519:
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 multikey has %d bytes", len(key))
+		}
+		return ed25519.PublicKey(key), nil
+	case multicodecRSA:
+		return x509.ParsePKCS1PublicKey(key)
+	default:
+		return nil, fmt.Errorf("unsupported multicodec 0x%x", codec)
+	}
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58Encode(b []byte) string {
+	x := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// leading zero bytes are encoded as leading 1s.
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	x := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(i)))
+	}
+	var zeros int
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), x.Bytes()...), nil
+}
@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultikey(t *testing.T) {
+	t.Run("ed25519", func(t *testing.T) {
+		require := require.New(t)
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(err)
+		s, err := EncodeMultikey(pub)
+		require.NoError(err)
+		require.True(strings.HasPrefix(s, "z6Mk"), s) // all ed25519 multikeys share this prefix
+		got, err := DecodeMultikey(s)
+		require.NoError(err)
+		require.Equal(pub, got)
+	})
+	t.Run("FEP-521a example", func(t *testing.T) {
+		got, err := DecodeMultikey("z6MkrJVnaZkeFzdQyMZu1cgjg7k1pZZ6pvBQ7XJPt4swbTQ2")
+		require.NoError(t, err)
+		require.IsType(t, ed25519.PublicKey{}, got)
+	})
+	t.Run("rsa", func(t *testing.T) {
+		require := require.New(t)
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(err)
+		s, err := EncodeMultikey(&key.PublicKey)
+		require.NoError(err)
+		got, err := DecodeMultikey(s)
+		require.NoError(err)
+		require.True(key.PublicKey.Equal(got))
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := DecodeMultikey("not-a-multikey")
+		require.Error(t, err)
+		_, err = DecodeMultikey("z0OIl")
+		require.Error(t, err)
+	})
+}
+
+func TestGenerateEd25519Keypair(t *testing.T) {
+	require := require.New(t)
+	kp, err := GenerateEd25519Keypair()
+	require.NoError(err)
+	priv, err := ParseEd25519PrivateKey(kp.PrivateKey)
+	require.NoError(err)
+	pub, err := ParsePublicKey(kp.PublicKey)
+	require.NoError(err)
+	require.True(priv.Public().(ed25519.PublicKey).Equal(pub))
+}
@@ -0,0 +1,365 @@
+package httpsig
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signatureLabel is the label of the signatures this package creates.
+const signatureLabel = "sig1"
+
+// signRFC9421 signs the request with an RFC 9421 HTTP Message Signature, covering
+// the method, target URI, and for requests with a body, the Content-Digest header.
+func signRFC9421(req *http.Request, keyID string, privateKey crypto.PrivateKey, body []byte, now time.Time) error {
+	components := []string{"@method", "@target-uri"}
+	if body != nil {
+		components = append(components, "content-digest")
+		sum := sha256.Sum256(body)
+		req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+	}
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = strconv.Quote(c)
+	}
+	var alg string
+	switch privateKey.(type) {
+	case *rsa.PrivateKey:
+		alg = "rsa-v1_5-sha256"
+	case ed25519.PrivateKey:
+		alg = "ed25519"
+	default:
+		return fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+	params := fmt.Sprintf(`(%s);created=%d;keyid=%s;alg=%q`, strings.Join(quoted, " "), now.Unix(), strconv.Quote(keyID), alg)
+	base, err := signatureBase(req, components, params)
+	if err != nil {
+		return err
+	}
+	_, sig, err := signBase(privateKey, base)
+	if err != nil {
+		return err
+	}
+	req.Header.Del("Signature")
+	req.Header.Set("Signature-Input", signatureLabel+"="+params)
+	req.Header.Set("Signature", signatureLabel+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// verifyRFC9421 verifies the RFC 9421 HTTP Message Signature of the request.
+// The signature must cover the method and target URI of the request, and for
+// requests with a body, the Content-Digest header, which must match the body.
+// The signature must have been created, or the Date header it covers must be,
+// within MaxSignatureAge before, or MaxClockSkew after, now.
+func verifyRFC9421(req *http.Request, keyFn KeyFunc, now time.Time) (string, error) {
+	input, err := parseSignatureInput(req.Header.Get("Signature-Input"), req.Header.Get("Signature"))
+	if err != nil {
+		return "", err
+	}
+	required := []string{"@method", "@target-uri"}
+	if hasBody(req) {
+		required = append(required, "content-digest")
+	}
+	for _, c := range required {
+		if !slices.Contains(input.components, c) {
+			return "", fmt.Errorf("%s is not signed", c)
+		}
+	}
+	switch {
+	case input.created != nil:
+		created := time.Unix(*input.created, 0)
+		if created.Before(now.Add(-MaxSignatureAge)) || created.After(now.Add(MaxClockSkew)) {
+			return "", fmt.Errorf("signature created at %v outside acceptable window", created)
+		}
+	case slices.Contains(input.components, "date"):
+		if err := checkDate(req, now); err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.New("signature has neither a created time nor a signed date")
+	}
+	if input.expires != nil && now.After(time.Unix(*input.expires, 0)) {
+		return "", errors.New("signature has expired")
+	}
+	if slices.Contains(input.components, "content-digest") {
+		if err := checkContentDigest(req); err != nil {
+			return "", err
+		}
+	}
+	base, err := signatureBase(req, input.components, input.params)
+	if err != nil {
+		return "", err
+	}
+	verify := func(pubKey crypto.PublicKey) error {
+		return verifyMessageSignature(input.alg, pubKey, base, input.signature)
+	}
+	pubKey, err := keyFn(input.keyID, false)
+	if err == nil {
+		if err = verify(pubKey); err == nil {
+			return input.keyID, nil
+		}
+	}
+	// the key may have been rotated since we last fetched it.
+	pubKey, err = keyFn(input.keyID, true)
+	if err != nil {
+		return "", err
+	}
+	if err := verify(pubKey); err != nil {
+		return "", err
+	}
+	return input.keyID, nil
+}
+
+// signatureInput is one signature from the Signature-Input and Signature headers.
+type signatureInput struct {
+	components []string
+	// params is the serialised inner list and parameters, as covered by the signature.
+	params    string
+	keyID     string
+	alg       string
+	created   *int64
+	expires   *int64
+	signature []byte
+}
+
+// parseSignatureInput returns the first signature in the Signature-Input header
+// for which the Signature header has a value.
+func parseSignatureInput(inputHeader, signatureHeader string) (*signatureInput, error) {
+	if inputHeader == "" || signatureHeader == "" {
+		return nil, errors.New("signature headers are missing")
+	}
+	signatures := make(map[string][]byte)
+	for _, member := range splitDictionary(signatureHeader) {
+		label, value, ok := strings.Cut(member, "=")
+		if !ok || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return nil, fmt.Errorf("malformed signature: %q", member)
+		}
+		sig, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed signature: %w", err)
+		}
+		signatures[label] = sig
+	}
+	for _, member := range splitDictionary(inputHeader) {
+		label, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed signature input: %q", member)
+		}
+		sig, ok := signatures[label]
+		if !ok {
+			continue
+		}
+		input, err := parseInnerList(value)
+		if err != nil {
+			return nil, err
+		}
+		input.signature = sig
+		return input, nil
+	}
+	return nil, errors.New("no signature matches the signature input")
+}
+
+// splitDictionary splits a structured field dictionary into its members,
+// ignoring commas inside strings and inner lists.
+func splitDictionary(s string) []string {
+	var members []string
+	var quoted bool
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '(':
+			depth++
+		case !quoted && c == ')':
+			depth--
+		case !quoted && depth == 0 && c == ',':
+			members = append(members, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(members, strings.TrimSpace(s[start:]))
+}
+
+// parseInnerList parses the covered components and parameters of a signature input.
+func parseInnerList(value string) (*signatureInput, error) {
+	if !strings.HasPrefix(value, "(") {
+		return nil, fmt.Errorf("malformed signature input: %q", value)
+	}
+	end := strings.IndexByte(value, ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed signature input: %q", value)
+	}
+	input := signatureInput{params: value}
+	for _, item := range strings.Fields(value[1:end]) {
+		c, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported component %s: %w", item, err)
+		}
+		input.components = append(input.components, strings.ToLower(c))
+	}
+	for _, param := range strings.Split(value[end+1:], ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "keyid", "alg":
+			s, err := strconv.Unquote(v)
+			if err != nil {
+				return nil, fmt.Errorf("malformed %s parameter: %w", k, err)
+			}
+			if k == "keyid" {
+				input.keyID = s
+			} else {
+				input.alg = s
+			}
+		case "created", "expires":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed %s parameter: %w", k, err)
+			}
+			if k == "created" {
+				input.created = &n
+			} else {
+				input.expires = &n
+			}
+		}
+	}
+	if input.keyID == "" {
+		return nil, errors.New("signature input has no keyid")
+	}
+	return &input, nil
+}
+
+// signatureBase returns the signature base of the covered components of the request.
+func signatureBase(req *http.Request, components []string, params string) ([]byte, error) {
+	var sb strings.Builder
+	for _, c := range components {
+		value, err := componentValue(req, c)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&sb, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&sb, "%q: %s", "@signature-params", params)
+	return []byte(sb.String()), nil
+}
+
+func componentValue(req *http.Request, component string) (string, error) {
+	switch component {
+	case "@method":
+		return strings.ToUpper(req.Method), nil
+	case "@target-uri":
+		return targetURI(req), nil
+	case "@authority":
+		return strings.ToLower(req.Host), nil
+	case "@scheme":
+		return "https", nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		return req.URL.EscapedPath(), nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	case "host":
+		return req.Host, nil
+	}
+	if strings.HasPrefix(component, "@") {
+		return "", fmt.Errorf("unsupported component: %s", component)
+	}
+	values := req.Header.Values(component)
+	if len(values) == 0 {
+		return "", fmt.Errorf("signed header %s is missing", component)
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return strings.Join(values, ", "), nil
+}
+
+// targetURI returns the absolute URI of the request. Incoming requests are
+// assumed to have been made over https.
+func targetURI(req *http.Request) string {
+	if req.URL.IsAbs() {
+		return req.URL.String()
+	}
+	return "https://" + req.Host + req.URL.RequestURI()
+}
+
+// checkContentDigest checks that the request's Content-Digest header matches its body.
+func checkContentDigest(req *http.Request) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	checked := false
+	for _, member := range splitDictionary(req.Header.Get("Content-Digest")) {
+		alg, value, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+		var sum []byte
+		switch strings.ToLower(alg) {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		want, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+		if err != nil {
+			return fmt.Errorf("malformed content digest: %w", err)
+		}
+		if subtle.ConstantTimeCompare(sum, want) != 1 {
+			return fmt.Errorf("%s content digest does not match body", alg)
+		}
+		checked = true
+	}
+	if !checked {
+		return fmt.Errorf("no supported digest in %q", req.Header.Get("Content-Digest"))
+	}
+	return nil
+}
+
+func verifyMessageSignature(alg string, pubKey crypto.PublicKey, base, sig []byte) error {
+	if key, ok := pubKey.(ed25519.PublicKey); ok {
+		if alg != "ed25519" && alg != "" {
+			return fmt.Errorf("algorithm %s does not match ed25519 key", alg)
+		}
+		if !ed25519.Verify(key, base, sig) {
+			return errors.New("ed25519 signature verification failed")
+		}
+		return nil
+	}
+	key, ok := pubKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key type: %T", pubKey)
+	}
+	switch alg {
+	case "rsa-v1_5-sha256", "":
+		digest := sha256.Sum256(base)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case "rsa-pss-sha512":
+		digest := sha512.Sum512(base)
+		return rsa.VerifyPSS(key, crypto.SHA512, digest[:], sig, nil)
+	default:
+		return fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+}
@@ -1,9 +1,10 @@
-// Package httpsig implements the HTTP Signature scheme as defined in draft-cavage-http-signatures-10.
+// Package httpsig implements the HTTP Signature scheme as defined in draft-cavage-http-signatures-10,
+// and HTTP Message Signatures as defined in RFC 9421.
 package httpsig
 
 import (
-	"bytes"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -12,8 +13,6 @@ import (
 	"net/http"
 	"strings"
 	"time"
-
-	"github.com/go-fed/httpsig"
 )
 
 const (
@@ -21,9 +20,43 @@ const (
 	RequestTarget = "(request-target)"
 )
 
-// Sign signs the request using the given keyID and privateKey.
-func Sign(req *http.Request, keyID string, privateKey crypto.PrivateKey, body []byte) error {
-	req.Header.Set("Date", time.Now().UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")) // Date must be in GMT, not UTC 🤯
+// Scheme is a scheme for signing HTTP requests.
+type Scheme int
+
+const (
+	// Cavage is draft-cavage-http-signatures, which most servers expect.
+	Cavage Scheme = iota
+	// RFC9421 is RFC 9421 HTTP Message Signatures.
+	RFC9421
+)
+
+func (s Scheme) String() string {
+	switch s {
+	case Cavage:
+		return "draft-cavage"
+	case RFC9421:
+		return "rfc9421"
+	default:
+		return fmt.Sprintf("Scheme(%d)", int(s))
+	}
+}
+
+// Other returns the scheme to fall back to if the server rejects this one.
+func (s Scheme) Other() Scheme {
+	if s == RFC9421 {
+		return Cavage
+	}
+	return RFC9421
+}
+
+// Sign signs the request with the given scheme using the given keyID and privateKey.
+// body is the body of the request, or nil if it has none.
+func Sign(req *http.Request, scheme Scheme, keyID string, privateKey crypto.PrivateKey, body []byte) error {
+	now := time.Now()
+	req.Header.Set("Date", now.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")) // Date must be in GMT, not UTC 🤯
+	if scheme == RFC9421 {
+		return signRFC9421(req, keyID, privateKey, body, now)
+	}
 	headersToSign := []string{
 		RequestTarget,
 	}
@@ -31,53 +64,39 @@ func Sign(req *http.Request, keyID string, privateKey crypto.PrivateKey, body []
 	case "GET":
 		headersToSign = append(headersToSign, "host", "date", "accept")
 	case "POST":
-		headersToSign = append(headersToSign, "date", "digest")
+		headersToSign = append(headersToSign, "host", "date", "digest")
 		addDigest(req, body)
 	}
 
-	var sb bytes.Buffer
-	for _, header := range headersToSign {
-		switch header {
-		case httpsig.RequestTarget:
-			sb.WriteString("(request-target): ")
-			sb.WriteString(strings.ToLower(req.Method))
-			sb.WriteString(" ")
-			sb.WriteString(req.URL.Path)
-
-			if req.URL.RawQuery != "" {
-				sb.WriteString("?")
-				sb.WriteString(req.URL.RawQuery)
-			}
-		case "Host", "host":
-			sb.WriteString("host: ")
-			sb.WriteString(req.Host)
-		case "Date", "date":
-			sb.WriteString("date: ")
-			sb.WriteString(req.Header.Get("Date"))
-		case "Accept", "accept":
-			sb.WriteString("accept: ")
-			sb.WriteString(req.Header.Get("Accept"))
-		case "Digest", "digest":
-			sb.WriteString("digest: ")
-			sb.WriteString(req.Header.Get("Digest"))
-		default:
-			return fmt.Errorf("unknown header to sign: %s", header)
-		}
-		sb.WriteString("\n")
+	base, err := signingString(req, headersToSign)
+	if err != nil {
+		return err
 	}
-	hash := sha256.New()
-	hash.Write(bytes.TrimRight(sb.Bytes(), "\n")) // remove trailing newline
-	digest := hash.Sum(nil)
-
-	sig, err := rsa.SignPKCS1v15(rand.Reader, privateKey.(*rsa.PrivateKey), crypto.SHA256, digest)
+	algorithm, sig, err := signBase(privateKey, base)
 	if err != nil {
 		return err
 	}
 	enc := base64.StdEncoding.EncodeToString(sig)
-	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`, keyID, strings.Join(headersToSign, " "), enc))
+	req.Header.Del("Signature-Input")
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="%s",headers="%s",signature="%s"`, keyID, algorithm, strings.Join(headersToSign, " "), enc))
 	return nil
 }
 
+// signBase signs the signature base with the private key, returning the
+// draft-cavage name of the algorithm used.
+func signBase(privateKey crypto.PrivateKey, base []byte) (string, []byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(base)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		return "rsa-sha256", sig, err
+	case ed25519.PrivateKey:
+		return "hs2019", ed25519.Sign(key, base), nil
+	default:
+		return "", nil, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+}
+
 func addDigest(req *http.Request, body []byte) {
 	hash := sha256.New()
 	hash.Write(body)
@@ -21,7 +21,7 @@ func TestSignRequest(t *testing.T) {
 	require.NoError(err)
 	pubKey := &privatekey.PublicKey
 
-	err = Sign(req, keyID, privatekey, nil)
+	err = Sign(req, Cavage, keyID, privatekey, nil)
 	require.NoError(err)
 
 	verifier, err := httpsig.NewVerifier(req)
@@ -1,101 +1,278 @@
 package httpsig
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
-// Verify verifies signature of the request.
-func Verify(req *http.Request, keyFn func(keyID string) (crypto.PublicKey, error)) error {
-	sigHeader := req.Header.Get("Signature")
-	if sigHeader == "" {
-		return errors.New("signature header is missing")
-	}
-
-	var (
-		pubKey  crypto.PublicKey
-		algo    string
-		sig     []byte
-		headers []string
-		err     error
-	)
-	for _, part := range strings.Split(sigHeader, ",") {
-		k, v := strings.SplitN(part, "=", 2)[0], strings.SplitN(part, "=", 2)[1]
+const (
+	// MaxSignatureAge is the oldest a signed request's Date header may be.
+	MaxSignatureAge = 12 * time.Hour
+
+	// MaxClockSkew is how far in the future a signed request's Date header may be.
+	MaxClockSkew = time.Hour
+)
+
+// KeyFunc returns the public key identified by keyID. If refresh is true the key
+// should be fetched again from its owner, as the one previously returned may have
+// been rotated. A KeyFunc may decline to fetch a key it has fetched recently.
+type KeyFunc func(keyID string, refresh bool) (crypto.PublicKey, error)
+
+// Verify verifies the signature of the request, returning the id of the key which
+// signed it. Requests with a Signature-Input header are verified as RFC 9421 HTTP
+// Message Signatures, otherwise as draft-cavage HTTP Signatures.
+// If the signature does not verify against the key returned by keyFn, the key is
+// refreshed and the signature checked once more.
+func Verify(req *http.Request, keyFn KeyFunc) (string, error) {
+	return verify(req, keyFn, time.Now())
+}
+
+func verify(req *http.Request, keyFn KeyFunc, now time.Time) (string, error) {
+	if req.Header.Get("Signature-Input") != "" {
+		return verifyRFC9421(req, keyFn, now)
+	}
+	return verifyCavage(req, keyFn, now)
+}
+
+// verifyCavage verifies the draft-cavage HTTP Signature of the request.
+// The signature must cover the (request-target), host, and date headers, and for
+// requests with a body, the digest header, which must match the body.
+// The Date header must be no older than MaxSignatureAge, and no more than
+// MaxClockSkew in the future.
+func verifyCavage(req *http.Request, keyFn KeyFunc, now time.Time) (string, error) {
+	sig, err := parseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return "", err
+	}
+	required := []string{RequestTarget, "host", "date"}
+	if hasBody(req) {
+		required = append(required, "digest")
+	}
+	for _, header := range required {
+		if !slices.Contains(sig.headers, header) {
+			return "", fmt.Errorf("%s header is not signed", header)
+		}
+	}
+	if err := checkDate(req, now); err != nil {
+		return "", err
+	}
+	if slices.Contains(sig.headers, "digest") {
+		if err := checkDigest(req); err != nil {
+			return "", err
+		}
+	}
+	base, err := signingString(req, sig.headers)
+	if err != nil {
+		return "", err
+	}
+
+	pubKey, err := keyFn(sig.keyID, false)
+	if err == nil {
+		if err = verifySignature(sig.algorithm, pubKey, base, sig.signature); err == nil {
+			return sig.keyID, nil
+		}
+	}
+	// the key may have been rotated since we last fetched it.
+	pubKey, err = keyFn(sig.keyID, true)
+	if err != nil {
+		return "", err
+	}
+	if err := verifySignature(sig.algorithm, pubKey, base, sig.signature); err != nil {
+		return "", err
+	}
+	return sig.keyID, nil
+}
+
+// signature is the parsed value of a Signature header.
+type signature struct {
+	keyID     string
+	algorithm string
+	headers   []string
+	signature []byte
+}
+
+func parseSignature(header string) (*signature, error) {
+	if header == "" {
+		return nil, errors.New("signature header is missing")
+	}
+	sig := signature{
+		// if the headers parameter is missing, only the Date header is signed.
+		headers: []string{"date"},
+	}
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed signature part: %q", part)
+		}
+		v = strings.Trim(v, `"`)
 		switch k {
 		case "keyId":
-			keyID := strings.Trim(v, "\"")
-			pubKey, err = keyFn(keyID)
-			if err != nil {
-				return err
-			}
+			sig.keyID = v
 		case "algorithm":
-			algo = strings.Trim(v, "\"")
+			sig.algorithm = strings.ToLower(v)
 		case "headers":
-			headers = strings.Split(strings.Trim(v, "\""), " ")
+			sig.headers = strings.Fields(strings.ToLower(v))
 		case "signature":
-			sig, err = base64.StdEncoding.DecodeString(strings.Trim(v, "\""))
+			b, err := base64.StdEncoding.DecodeString(v)
 			if err != nil {
-				return err
+				return nil, fmt.Errorf("malformed signature: %w", err)
 			}
+			sig.signature = b
+		default:
+			// ignore parameters we do not use, such as created and expires.
+		}
+	}
+	if sig.keyID == "" {
+		return nil, errors.New("signature has no keyId")
+	}
+	if len(sig.signature) == 0 {
+		return nil, errors.New("signature has no signature")
+	}
+	return &sig, nil
+}
+
+// checkDate checks that the request's Date header is within MaxSignatureAge
+// before, or MaxClockSkew after, now.
+func checkDate(req *http.Request, now time.Time) error {
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("invalid date header: %w", err)
+	}
+	if date.Before(now.Add(-MaxSignatureAge)) || date.After(now.Add(MaxClockSkew)) {
+		return fmt.Errorf("date header %q outside acceptable window", req.Header.Get("Date"))
+	}
+	return nil
+}
+
+// hasBody returns true if the request carries, or could carry, a body.
+func hasBody(req *http.Request) bool {
+	switch req.Method {
+	case "POST", "PUT", "PATCH":
+		return true
+	default:
+		return req.ContentLength > 0
+	}
+}
+
+// checkDigest checks that the request's Digest header matches its body.
+// The body is read and replaced so that it may be read again.
+func checkDigest(req *http.Request) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+	checked := false
+	for _, part := range strings.Split(req.Header.Get("Digest"), ",") {
+		alg, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		var sum []byte
+		switch strings.ToUpper(alg) {
+		case "SHA-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "SHA-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
 		default:
-			return fmt.Errorf("unknown signature part: %s", part)
+			continue
 		}
+		want, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("malformed digest: %w", err)
+		}
+		if subtle.ConstantTimeCompare(sum, want) != 1 {
+			return fmt.Errorf("%s digest does not match body", alg)
+		}
+		checked = true
+	}
+	if !checked {
+		return fmt.Errorf("no supported digest in %q", req.Header.Get("Digest"))
+	}
+	return nil
+}
+
+// readBody returns the body of the request, replacing it so that it may be read again.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
 	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
 
-	var sb strings.Builder
-	for _, header := range headers {
+// signingString returns the string covered by a signature of the given headers.
+func signingString(req *http.Request, headers []string) ([]byte, error) {
+	var sb bytes.Buffer
+	for i, header := range headers {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
 		switch header {
 		case RequestTarget:
 			sb.WriteString("(request-target): ")
 			sb.WriteString(strings.ToLower(req.Method))
 			sb.WriteString(" ")
 			sb.WriteString(req.URL.Path)
-
 			if req.URL.RawQuery != "" {
 				sb.WriteString("?")
 				sb.WriteString(req.URL.RawQuery)
 			}
-		case "Host", "host":
+		case "host":
 			sb.WriteString("host: ")
 			sb.WriteString(req.Host)
-		case "Date", "date":
-			sb.WriteString("date: ")
-			sb.WriteString(req.Header.Get("Date"))
-		case "Accept", "accept":
-			sb.WriteString("accept: ")
-			sb.WriteString(req.Header.Get("Accept"))
-		case "Digest", "digest":
-			sb.WriteString("digest: ")
-			sb.WriteString(req.Header.Get("Digest"))
 		default:
-			return fmt.Errorf("unknown header to sign: %s", header)
+			values := req.Header.Values(header)
+			if len(values) == 0 {
+				return nil, fmt.Errorf("signed header %s is missing", header)
+			}
+			sb.WriteString(header)
+			sb.WriteString(": ")
+			sb.WriteString(strings.Join(values, ", "))
 		}
-		sb.WriteString("\n")
 	}
-	hash := sha256.New()
-	io.WriteString(hash, strings.TrimRight(sb.String(), "\n")) // remove trailing newline
-	digest := hash.Sum(nil)
+	return sb.Bytes(), nil
+}
 
-	switch algo {
-	case "rsa-sha256":
-		return rsaVerify(pubKey, digest, sig)
+func verifySignature(algorithm string, pubKey crypto.PublicKey, base, sig []byte) error {
+	switch algorithm {
+	case "rsa-sha256", "hs2019", "":
+		// hs2019, and signatures without an algorithm, use the algorithm of the key.
 	default:
-		return fmt.Errorf("unknown algorithm: %s", algo)
+		return fmt.Errorf("unsupported algorithm: %s", algorithm)
 	}
-}
-
-func rsaVerify(pubKey crypto.PublicKey, digest, sig []byte) error {
 	switch key := pubKey.(type) {
 	case *rsa.PublicKey:
-		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig)
+		digest := sha256.Sum256(base)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if algorithm == "rsa-sha256" {
+			return errors.New("algorithm rsa-sha256 does not match ed25519 key")
+		}
+		if !ed25519.Verify(key, base, sig) {
+			return errors.New("ed25519 signature verification failed")
+		}
+		return nil
 	default:
-		return fmt.Errorf("unknown public key type: %T", key)
+		return fmt.Errorf("unsupported public key type: %T", key)
 	}
 }
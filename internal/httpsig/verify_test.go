@@ -0,0 +1,235 @@
+package httpsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	const keyID = "https://example.org/users/bob#main-key"
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFn := func(string, bool) (crypto.PublicKey, error) {
+		return &privateKey.PublicKey, nil
+	}
+
+	post := func(t *testing.T, body string) *http.Request {
+		req, err := http.NewRequest("POST", "https://example.com/inbox", strings.NewReader(body))
+		require.NoError(t, err)
+		require.NoError(t, Sign(req, Cavage, keyID, privateKey, []byte(body)))
+		return req
+	}
+
+	t.Run("signed POST", func(t *testing.T) {
+		require := require.New(t)
+		req := post(t, `{"type":"Like"}`)
+		got, err := Verify(req, keyFn)
+		require.NoError(err)
+		require.Equal(keyID, got)
+
+		// the body can be read again after verification
+		body, err := io.ReadAll(req.Body)
+		require.NoError(err)
+		require.Equal(`{"type":"Like"}`, string(body))
+	})
+	t.Run("signed GET", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "https://example.com/u/alice", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/activity+json")
+		require.NoError(t, Sign(req, Cavage, keyID, privateKey, nil))
+		_, err = Verify(req, keyFn)
+		require.NoError(t, err)
+	})
+	t.Run("body does not match digest", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Body = io.NopCloser(bytes.NewReader([]byte(`{"type":"Delete"}`)))
+		_, err := Verify(req, keyFn)
+		require.Error(t, err)
+	})
+	t.Run("digest not signed", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), " digest", "", 1))
+		_, err := Verify(req, keyFn)
+		require.ErrorContains(t, err, "digest header is not signed")
+	})
+	t.Run("host not signed", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), " host", "", 1))
+		_, err := Verify(req, keyFn)
+		require.ErrorContains(t, err, "host header is not signed")
+	})
+	t.Run("request target not signed", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), RequestTarget+" ", "", 1))
+		_, err := Verify(req, keyFn)
+		require.ErrorContains(t, err, "(request-target) header is not signed")
+	})
+	t.Run("date not signed", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), " date", "", 1))
+		_, err := Verify(req, keyFn)
+		require.ErrorContains(t, err, "date header is not signed")
+	})
+	t.Run("tampered request target", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.URL.Path = "/u/alice/inbox"
+		_, err := Verify(req, keyFn)
+		require.Error(t, err)
+	})
+	t.Run("key is refreshed once on failure", func(t *testing.T) {
+		require := require.New(t)
+		stale, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(err)
+		var calls []bool
+		_, err = Verify(post(t, `{}`), func(_ string, refresh bool) (crypto.PublicKey, error) {
+			calls = append(calls, refresh)
+			if refresh {
+				return &privateKey.PublicKey, nil
+			}
+			return &stale.PublicKey, nil
+		})
+		require.NoError(err)
+		require.Equal([]bool{false, true}, calls)
+	})
+	t.Run("key cannot be found", func(t *testing.T) {
+		_, err := Verify(post(t, `{}`), func(string, bool) (crypto.PublicKey, error) {
+			return nil, errors.New("gone")
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestVerifyRFC9421(t *testing.T) {
+	const keyID = "https://example.org/users/bob#main-key"
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFn := func(string, bool) (crypto.PublicKey, error) {
+		return &privateKey.PublicKey, nil
+	}
+
+	post := func(t *testing.T, body string) *http.Request {
+		req, err := http.NewRequest("POST", "https://example.com/inbox", strings.NewReader(body))
+		require.NoError(t, err)
+		require.NoError(t, Sign(req, RFC9421, keyID, privateKey, []byte(body)))
+		return req
+	}
+	// incoming requests have a relative URL.
+	incoming := func(req *http.Request) *http.Request {
+		in := req.Clone(req.Context())
+		in.URL = &url.URL{Path: req.URL.Path, RawQuery: req.URL.RawQuery}
+		in.Body = req.Body
+		return in
+	}
+
+	t.Run("signed POST", func(t *testing.T) {
+		require := require.New(t)
+		req := post(t, `{"type":"Like"}`)
+		require.NotEmpty(req.Header.Get("Signature-Input"))
+		require.NotEmpty(req.Header.Get("Content-Digest"))
+		got, err := Verify(incoming(req), keyFn)
+		require.NoError(err)
+		require.Equal(keyID, got)
+	})
+	t.Run("signed GET", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "https://example.com/u/alice?page=true", nil)
+		require.NoError(t, err)
+		require.NoError(t, Sign(req, RFC9421, keyID, privateKey, nil))
+		_, err = Verify(incoming(req), keyFn)
+		require.NoError(t, err)
+	})
+	t.Run("body does not match content digest", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Body = io.NopCloser(bytes.NewReader([]byte(`{"type":"Delete"}`)))
+		_, err := Verify(incoming(req), keyFn)
+		require.ErrorContains(t, err, "does not match body")
+	})
+	t.Run("content digest not signed", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Header.Set("Signature-Input", strings.Replace(req.Header.Get("Signature-Input"), ` "content-digest"`, "", 1))
+		_, err := Verify(incoming(req), keyFn)
+		require.ErrorContains(t, err, "content-digest is not signed")
+	})
+	t.Run("tampered target", func(t *testing.T) {
+		req := incoming(post(t, `{"type":"Like"}`))
+		req.URL.Path = "/u/alice/inbox"
+		_, err := Verify(req, keyFn)
+		require.Error(t, err)
+	})
+	t.Run("stale signature", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		_, err := verify(incoming(req), keyFn, time.Now().Add(MaxSignatureAge+time.Minute))
+		require.ErrorContains(t, err, "outside acceptable window")
+	})
+	t.Run("unknown labels are skipped", func(t *testing.T) {
+		req := post(t, `{"type":"Like"}`)
+		req.Header.Set("Signature-Input", `other=("@method");created=1;keyid="x", `+req.Header.Get("Signature-Input"))
+		_, err := Verify(incoming(req), keyFn)
+		require.NoError(t, err)
+	})
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	const keyID = "https://example.org/users/bob#ed25519-key"
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyFn := func(string, bool) (crypto.PublicKey, error) {
+		return pub, nil
+	}
+
+	for _, scheme := range []Scheme{Cavage, RFC9421} {
+		t.Run(scheme.String(), func(t *testing.T) {
+			require := require.New(t)
+			body := []byte(`{"type":"Like"}`)
+			req, err := http.NewRequest("POST", "https://example.com/inbox", bytes.NewReader(body))
+			require.NoError(err)
+			require.NoError(Sign(req, scheme, keyID, priv, body))
+			got, err := Verify(req, keyFn)
+			require.NoError(err)
+			require.Equal(keyID, got)
+
+			// an rsa key does not verify an ed25519 signature
+			rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			require.NoError(err)
+			_, err = Verify(req, func(string, bool) (crypto.PublicKey, error) {
+				return &rsaKey.PublicKey, nil
+			})
+			require.Error(err)
+		})
+	}
+}
+
+func TestCheckDate(t *testing.T) {
+	now := time.Now()
+	request := func(date time.Time) *http.Request {
+		r, _ := http.NewRequest("POST", "https://example.com/inbox", nil)
+		r.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+		return r
+	}
+
+	t.Run("fresh date", func(t *testing.T) {
+		require.NoError(t, checkDate(request(now.Add(-time.Minute)), now))
+	})
+	t.Run("stale date", func(t *testing.T) {
+		require.Error(t, checkDate(request(now.Add(-MaxSignatureAge-time.Minute)), now))
+	})
+	t.Run("date in the future", func(t *testing.T) {
+		require.Error(t, checkDate(request(now.Add(MaxClockSkew+time.Minute)), now))
+	})
+	t.Run("missing date", func(t *testing.T) {
+		r := request(now)
+		r.Header.Del("Date")
+		require.Error(t, checkDate(r, now))
+	})
+}
@@ -26,13 +26,19 @@ var cli struct {
 	LogSQL bool   `help:"Log SQL queries."`
 	DSN    string `help:"data source name" default:"pub:pub@tcp(localhost:3306)/pub"`
 
+	AddAccountKey        AddAccountKeyCmd        `cmd:"" help:"Add an Ed25519 key to an account."`
 	AutoMigrate          AutoMigrateCmd          `cmd:"" help:"Automigrate the database."`
+	BlockDomain          BlockDomainCmd          `cmd:"" help:"Block a domain."`
 	CreateAccount        CreateAccountCmd        `cmd:"" help:"Create a new account."`
 	CreateInstance       CreateInstanceCmd       `cmd:"" help:"Create a new instance."`
 	DeleteAccount        DeleteAccountCmd        `cmd:"" help:"Delete an account."`
 	FetchActor           FetchActorCmd           `cmd:"" help:"Fetch an actor."`
 	HouseKeeping         HouseKeepingCmd         `cmd:"" help:"Perform housekeeping."`
+	MoveAccount          MoveAccountCmd          `cmd:"" help:"Move an account to another actor."`
+	Reports              ReportsCmd              `cmd:"" help:"List unresolved reports, or resolve a report."`
+	RotateKeys           RotateKeysCmd           `cmd:"" help:"Rotate the keys of an account."`
 	Serve                ServeCmd                `cmd:"" help:"Serve a local web server."`
+	SetAccountAliases    SetAccountAliasesCmd    `cmd:"" help:"Set the aliases of an account."`
 	ShowActor            ShowActorCmd            `cmd:"" help:"Display an actor."`
 	SynchroniseFollowers SynchroniseFollowersCmd `cmd:"" help:"Synchronise followers."`
 	RerunObjectHooks     RerunObjectHooksCmd     `cmd:"" help:"Rerun object hooks."`
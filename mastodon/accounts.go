@@ -1,7 +1,12 @@
 package mastodon
 
 import (
+	"errors"
+	"fmt"
+	"html"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/davecheney/pub/internal/algorithms"
 	"github.com/davecheney/pub/internal/httpx"
@@ -49,6 +54,7 @@ func AccountsStatusesShow(env *Env, w http.ResponseWriter, r *http.Request) erro
 	)
 	query = query.Preload("Reaction", &models.Reaction{ActorID: user.Actor.ObjectID}).Preload("Reaction.Actor").Preload("Reaction.Actor.Object") // reactions
 	query = query.Preload("Reblog.Reaction", &models.Reaction{ActorID: user.Actor.ObjectID}).Preload("Reblog.Reaction.Actor").Preload("Reblog.Reaction.Actor.Object")
+	query = query.Preload("Poll.Votes", "actor_id = ?", user.Actor.ObjectID).Preload("Reblog.Poll.Votes", "actor_id = ?", user.Actor.ObjectID)
 	if err := query.Where("statuses.actor_id = ?", chi.URLParam(r, "id")).Find(&statuses).Error; err != nil {
 		return err
 	}
@@ -63,10 +69,17 @@ func AccountsStatusesShow(env *Env, w http.ResponseWriter, r *http.Request) erro
 }
 
 func AccountsFollowersShow(env *Env, w http.ResponseWriter, r *http.Request) error {
-	_, err := env.authenticate(r)
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	hidden, err := collectionsHidden(env, user, r)
 	if err != nil {
 		return err
 	}
+	if hidden {
+		return to.JSON(w, []any{})
+	}
 
 	var followers []*models.Relationship
 	if err := env.DB.Scopes(models.PaginateRelationship(r), models.PreloadRelationshipTarget).Where("actor_id = ? and followed_by = true", chi.URLParam(r, "id")).Find(&followers).Error; err != nil {
@@ -85,10 +98,17 @@ func relationshipTarget(rel *models.Relationship) *models.Actor {
 }
 
 func AccountsFollowingShow(env *Env, w http.ResponseWriter, r *http.Request) error {
-	_, err := env.authenticate(r)
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	hidden, err := collectionsHidden(env, user, r)
 	if err != nil {
 		return err
 	}
+	if hidden {
+		return to.JSON(w, []any{})
+	}
 	var following []*models.Relationship
 	if err := env.DB.Scopes(models.PaginateRelationship(r), models.PreloadRelationshipTarget).Where("actor_id = ? and following = true", chi.URLParam(r, "id")).Find(&following).Error; err != nil {
 		return err
@@ -101,28 +121,83 @@ func AccountsFollowingShow(env *Env, w http.ResponseWriter, r *http.Request) err
 	return to.JSON(w, algorithms.Map(algorithms.Map(following, relationshipTarget), serialise.Account))
 }
 
+// collectionsHidden reports whether the account named in the request hides its
+// followers and following from the user.
+func collectionsHidden(env *Env, user *models.Account, r *http.Request) (bool, error) {
+	id, err := snowflake.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return false, httpx.Error(http.StatusBadRequest, err)
+	}
+	if id == user.Actor.ObjectID {
+		return false, nil
+	}
+	return models.NewAccounts(env.DB).HidesCollections(id)
+}
+
 func AccountsUpdateCredentials(env *Env, w http.ResponseWriter, r *http.Request) error {
 	account, err := env.authenticate(r)
 	if err != nil {
 		return err
 	}
 
-	if err := r.ParseForm(); err != nil {
+	if err := r.ParseMultipartForm(8 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
 		return httpx.Error(http.StatusBadRequest, err)
 	}
 
-	// if r.Form.Get("display_name") != "" {
-	// 	account.Actor.DisplayName = r.Form.Get("display_name")
-	// }
-	// if r.Form.Get("note") != "" {
-	// 	account.Actor.Note = r.Form.Get("note")
-	// }
+	props := make(map[string]any)
+	if r.Form.Has("display_name") {
+		props["name"] = r.Form.Get("display_name")
+	}
+	if r.Form.Has("note") {
+		props["summary"] = models.NewActors(env.DB).FormatNote(account.Actor, r.Form.Get("note"))
+	}
+	if r.Form.Has("locked") {
+		locked, _ := strconv.ParseBool(r.Form.Get("locked"))
+		props["manuallyApprovesFollowers"] = locked
+	}
+	if fields, ok := fieldsAttributes(r.Form); ok {
+		props["attachment"] = fields
+	}
 
-	if err := env.DB.Save(account).Error; err != nil {
-		return err
+	if len(props) > 0 {
+		if err := models.NewActors(env.DB).Update(account.Actor, props); err != nil {
+			return err
+		}
+	}
+	if r.Form.Has("hide_collections") {
+		hide, _ := strconv.ParseBool(r.Form.Get("hide_collections"))
+		if err := env.DB.Model(account).UpdateColumn("hide_collections", hide).Error; err != nil {
+			return err
+		}
 	}
 	serialise := Serialiser{req: r}
-	return to.JSON(w, serialise.Account(account.Actor))
+	return to.JSON(w, serialise.CredentialAccount(account))
+}
+
+// fieldsAttributes returns the profile metadata fields encoded in the form as
+// fields_attributes[n][name] and fields_attributes[n][value], as PropertyValue
+// attachments. The boolean is false if the form does not contain any fields.
+func fieldsAttributes(form url.Values) ([]any, bool) {
+	found := false
+	fields := []any{}
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("fields_attributes[%d][name]", i)
+		value := fmt.Sprintf("fields_attributes[%d][value]", i)
+		if !form.Has(name) && !form.Has(value) {
+			continue
+		}
+		found = true
+		if form.Get(name) == "" {
+			// an empty name removes the field
+			continue
+		}
+		fields = append(fields, map[string]any{
+			"type":  "PropertyValue",
+			"name":  form.Get(name),
+			"value": html.EscapeString(form.Get(value)),
+		})
+	}
+	return fields, found
 }
 
 func AccountsShowListMembership(env *Env, w http.ResponseWriter, r *http.Request) error {
@@ -182,5 +257,59 @@ func AccountsFeaturedTagsShow(env *Env, w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return err
 	}
-	return to.JSON(w, []any{})
+	var actor models.Actor
+	if err := env.DB.Take(&actor, "object_id = ? ", chi.URLParam(r, "id")).Error; err != nil {
+		return httpx.Error(http.StatusNotFound, err)
+	}
+	featured, err := models.NewFeaturedTags(env.DB).FindByActor(&actor)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, algorithms.Map(featured, serialise.FeaturedTag))
+}
+
+// AccountsAliasesUpdate replaces the aliases of the authenticated account.
+// Aliases are the URIs of actors which may move to this account.
+func AccountsAliasesUpdate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Aliases []string `schema:"aliases[]"`
+	}
+	if err := httpx.Params(r, &params); err != nil {
+		return err
+	}
+	if err := models.NewActors(env.DB).SetAliases(user.Actor, params.Aliases); err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.CredentialAccount(user))
+}
+
+// AccountsMoveCreate moves the authenticated account to the target actor.
+// The target must already list this account as one of its aliases.
+func AccountsMoveCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Target string `schema:"target"`
+	}
+	if err := httpx.Params(r, &params); err != nil {
+		return err
+	}
+	actors := models.NewActors(env.DB)
+	target, err := actors.Fetch(params.Target)
+	if err != nil {
+		return httpx.Error(http.StatusUnprocessableEntity, err)
+	}
+	if err := actors.Move(user.Actor, target); err != nil {
+		return httpx.Error(http.StatusUnprocessableEntity, err)
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.CredentialAccount(user))
 }
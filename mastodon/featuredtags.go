@@ -0,0 +1,67 @@
+package mastodon
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/davecheney/pub/internal/algorithms"
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/internal/to"
+	"github.com/davecheney/pub/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func FeaturedTagsIndex(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	featured, err := models.NewFeaturedTags(env.DB).FindByActor(user.Actor)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, algorithms.Map(featured, serialise.FeaturedTag))
+}
+
+func FeaturedTagsCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Name string `json:"name" schema:"name,required"`
+	}
+	if err := httpx.Params(r, &params); err != nil {
+		return err
+	}
+	featured, err := models.NewFeaturedTags(env.DB).Create(user.Actor, params.Name)
+	if err != nil {
+		if errors.Is(err, models.ErrTooManyFeaturedTags) {
+			return httpx.Error(http.StatusUnprocessableEntity, err)
+		}
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.FeaturedTag(featured))
+}
+
+func FeaturedTagsDestroy(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		return httpx.Error(http.StatusBadRequest, err)
+	}
+	if err := models.NewFeaturedTags(env.DB).Delete(user.Actor, uint32(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return httpx.Error(http.StatusNotFound, err)
+		}
+		return err
+	}
+	return to.JSON(w, map[string]any{})
+}
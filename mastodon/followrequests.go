@@ -0,0 +1,84 @@
+package mastodon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davecheney/pub/internal/algorithms"
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/internal/to"
+	"github.com/davecheney/pub/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func FollowRequestsIndex(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var requests []*models.Relationship
+	if err := env.DB.Scopes(models.PaginateRelationship(r), models.PreloadRelationshipTarget).Where("actor_id = ? and requested_by = true", user.Actor.ObjectID).Find(&requests).Error; err != nil {
+		return err
+	}
+
+	if len(requests) > 0 {
+		linkHeader(w, r, requests[0].TargetID, requests[len(requests)-1].TargetID)
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, algorithms.Map(algorithms.Map(requests, relationshipTarget), serialise.Account))
+}
+
+func FollowRequestsAuthorize(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	follower, err := findFollowRequester(env, r, user.Actor)
+	if err != nil {
+		return err
+	}
+	rel, err := models.NewRelationships(env.DB).AuthorizeFollow(user.Actor, follower)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Relationship(rel))
+}
+
+func FollowRequestsReject(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	follower, err := findFollowRequester(env, r, user.Actor)
+	if err != nil {
+		return err
+	}
+	rel, err := models.NewRelationships(env.DB).RejectFollow(user.Actor, follower)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Relationship(rel))
+}
+
+// findFollowRequester returns the actor identified by the id in the URL, who
+// must have a pending request to follow the actor.
+func findFollowRequester(env *Env, r *http.Request, actor *models.Actor) (*models.Actor, error) {
+	var follower models.Actor
+	if err := env.DB.Take(&follower, chi.URLParam(r, "id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, httpx.Error(http.StatusNotFound, err)
+		}
+		return nil, err
+	}
+	var pending int64
+	if err := env.DB.Model(&models.Relationship{}).Where("actor_id = ? and target_id = ? and requested_by = true", actor.ObjectID, follower.ObjectID).Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending == 0 {
+		return nil, httpx.Error(http.StatusNotFound, errors.New("no pending follow request"))
+	}
+	return &follower, nil
+}
@@ -198,5 +198,10 @@ func InstancesActivityShow(env *Env, w http.ResponseWriter, r *http.Request) err
 }
 
 func InstancesDomainBlocksShow(env *Env, w http.ResponseWriter, r *http.Request) error {
-	return to.JSON(w, []map[string]interface{}{})
+	blocks, err := models.NewDomainBlocks(env.DB).FindAll()
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, algorithms.Map(blocks, serialise.DomainBlock))
 }
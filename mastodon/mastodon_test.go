@@ -3,6 +3,7 @@ package mastodon
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/davecheney/pub/internal/snowflake"
@@ -25,3 +26,21 @@ func TestLinkHeader(t *testing.T) {
 		`<https://example.com/api/v1/timelines/public?max_id=110330528023225442>; rel="next", <https://example.com/api/v1/timelines/public?min_id=110330528023226442>; rel="prev"`,
 	})
 }
+
+func TestFieldsAttributes(t *testing.T) {
+	require := require.New(t)
+
+	_, ok := fieldsAttributes(url.Values{"note": {"hello"}})
+	require.False(ok)
+
+	fields, ok := fieldsAttributes(url.Values{
+		"fields_attributes[0][name]":  {"Website"},
+		"fields_attributes[0][value]": {"https://example.com"},
+		"fields_attributes[1][name]":  {""},
+		"fields_attributes[1][value]": {"removed"},
+	})
+	require.True(ok)
+	require.Equal([]any{
+		map[string]any{"type": "PropertyValue", "name": "Website", "value": "https://example.com"},
+	}, fields)
+}
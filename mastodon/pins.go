@@ -0,0 +1,72 @@
+package mastodon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/internal/to"
+	"github.com/davecheney/pub/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func PinsCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	status, err := findPinnableStatus(env, user.Actor, r)
+	if err != nil {
+		return err
+	}
+	switch status.Visibility {
+	case "public", "unlisted":
+		// ok
+	default:
+		return httpx.Error(http.StatusUnprocessableEntity, errors.New("only public and unlisted statuses can be pinned"))
+	}
+	reaction, err := models.NewReactions(env.DB).Pin(status, user.Actor)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Status(reaction.Status))
+}
+
+func PinsDestroy(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	status, err := findPinnableStatus(env, user.Actor, r)
+	if err != nil {
+		return err
+	}
+	reaction, err := models.NewReactions(env.DB).Unpin(status, user.Actor)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Status(reaction.Status))
+}
+
+// findPinnableStatus returns the status named in the request, which must be written
+// by the actor, and not a reblog, as only their own statuses can be pinned to their profile.
+func findPinnableStatus(env *Env, actor *models.Actor, r *http.Request) (*models.Status, error) {
+	var status models.Status
+	query := env.DB.Joins("Actor").Scopes(models.PreloadStatus, models.PreloadReaction(actor))
+	if err := query.Take(&status, chi.URLParam(r, "id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, httpx.Error(http.StatusNotFound, err)
+		}
+		return nil, err
+	}
+	if status.ActorID != actor.ObjectID {
+		return nil, httpx.Error(http.StatusUnprocessableEntity, errors.New("only your own statuses can be pinned"))
+	}
+	if status.ReblogID != nil {
+		return nil, httpx.Error(http.StatusUnprocessableEntity, errors.New("reblogs cannot be pinned"))
+	}
+	return &status, nil
+}
@@ -0,0 +1,74 @@
+package mastodon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/internal/snowflake"
+	"github.com/davecheney/pub/internal/to"
+	"github.com/davecheney/pub/models"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func PollsShow(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	poll, err := findPoll(env, r, user.Actor)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Poll(poll))
+}
+
+// PollsVotesCreate votes on the poll for the options at the given indexes.
+func PollsVotesCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Choices []int `json:"choices" schema:"choices[]"`
+	}
+	if err := httpx.Params(r, &params); err != nil {
+		return err
+	}
+	poll, err := findPoll(env, r, user.Actor)
+	if err != nil {
+		return err
+	}
+	polls := models.NewPolls(env.DB)
+	if err := polls.Vote(poll, user.Actor, params.Choices); err != nil {
+		switch {
+		case errors.Is(err, models.ErrPollExpired), errors.Is(err, models.ErrAlreadyVoted), errors.Is(err, models.ErrInvalidChoice):
+			return httpx.Error(http.StatusUnprocessableEntity, err)
+		default:
+			return err
+		}
+	}
+	poll, err = polls.FindByID(poll.StatusID, user.Actor)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Poll(poll))
+}
+
+func findPoll(env *Env, r *http.Request, actor *models.Actor) (*models.StatusPoll, error) {
+	id, err := snowflake.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, httpx.Error(http.StatusBadRequest, err)
+	}
+	poll, err := models.NewPolls(env.DB).FindByID(id, actor)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, httpx.Error(http.StatusNotFound, err)
+		}
+		return nil, err
+	}
+	return poll, nil
+}
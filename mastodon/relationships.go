@@ -44,16 +44,15 @@ func RelationshipsCreate(env *Env, w http.ResponseWriter, req *http.Request) err
 		return err
 	}
 	var target models.Actor
-	if err := env.DB.First(&target, chi.URLParam(req, "id")).Error; err != nil {
+	if err := env.DB.Scopes(models.PreloadActor).First(&target, chi.URLParam(req, "id")).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return httpx.Error(http.StatusNotFound, err)
 		}
 		return err
 	}
-	rel, err := models.NewRelationships(env.DB).Follow(user.Actor, &target)
+	rel, err := models.NewRelationships(env.DB).FollowOrRequest(user.Actor, &target)
 	if err != nil {
 		return err
-
 	}
 	serialise := Serialiser{req: req}
 	return to.JSON(w, serialise.Relationship(rel))
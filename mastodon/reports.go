@@ -0,0 +1,65 @@
+package mastodon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/davecheney/pub/internal/httpx"
+	"github.com/davecheney/pub/internal/snowflake"
+	"github.com/davecheney/pub/internal/to"
+	"github.com/davecheney/pub/models"
+	"gorm.io/gorm"
+)
+
+// ReportsCreate reports an account, and optionally some of its statuses, to the moderators.
+// If forward is set and the account is remote, the report is forwarded anonymously to its server.
+func ReportsCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		AccountID snowflake.ID   `json:"account_id,string" schema:"account_id,required"`
+		StatusIDs []snowflake.ID `json:"status_ids,string" schema:"status_ids[]"`
+		Comment   string         `json:"comment" schema:"comment"`
+		Forward   BoolOrBit      `json:"forward" schema:"forward"`
+		Category  string         `json:"category" schema:"category"`
+	}
+	if err := httpx.Params(r, &params); err != nil {
+		return err
+	}
+	var category models.ReportCategory
+	switch params.Category {
+	case "":
+		category = "other"
+	case "spam", "legal", "violation", "other":
+		category = models.ReportCategory(params.Category)
+	default:
+		return httpx.Error(http.StatusUnprocessableEntity, errors.New("invalid category"))
+	}
+
+	var target models.Actor
+	if err := env.DB.Scopes(models.PreloadActor).Take(&target, params.AccountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return httpx.Error(http.StatusNotFound, err)
+		}
+		return err
+	}
+	var statuses []*models.Status
+	if len(params.StatusIDs) > 0 {
+		// only the target's own statuses may be attached to the report.
+		if err := env.DB.Where("object_id IN ? AND actor_id = ?", params.StatusIDs, target.ObjectID).Find(&statuses).Error; err != nil {
+			return err
+		}
+		if len(statuses) != len(params.StatusIDs) {
+			return httpx.Error(http.StatusUnprocessableEntity, errors.New("status does not belong to the reported account"))
+		}
+	}
+
+	report, err := models.NewReports(env.DB).Create(user.Actor, &target, statuses, category, params.Comment, bool(params.Forward))
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Report(report))
+}
@@ -1,6 +1,7 @@
 package mastodon
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"time"
@@ -164,6 +165,7 @@ type Relationship struct {
 	Muting              bool         `json:"muting"`
 	MutingNotifications bool         `json:"muting_notifications"`
 	Requested           bool         `json:"requested"`
+	RequestedBy         bool         `json:"requested_by"`
 	DomainBlocking      bool         `json:"domain_blocking"`
 	Endorsed            bool         `json:"endorsed"`
 	Note                string       `json:"note"`
@@ -180,12 +182,50 @@ func (s *Serialiser) Relationship(rel *models.Relationship) *Relationship {
 		BlockedBy:           rel.BlockedBy,
 		Muting:              rel.Muting,
 		MutingNotifications: false,
-		Requested:           false,
+		Requested:           rel.Requested,
+		RequestedBy:         rel.RequestedBy,
 		DomainBlocking:      false,
 		Endorsed:            false,
 	}
 }
 
+// Report is a representation of a Mastodon Report object.
+// https://docs.joinmastodon.org/entities/Report/
+type Report struct {
+	ID            snowflake.ID   `json:"id,string"`
+	ActionTaken   bool           `json:"action_taken"`
+	ActionTakenAt *string        `json:"action_taken_at"`
+	Category      string         `json:"category"`
+	Comment       string         `json:"comment"`
+	Forwarded     bool           `json:"forwarded"`
+	CreatedAt     string         `json:"created_at"`
+	StatusIDs     []snowflake.ID `json:"status_ids,string"`
+	RuleIDs       []string       `json:"rule_ids"`
+	TargetAccount *Account       `json:"target_account"`
+}
+
+func (s *Serialiser) Report(r *models.Report) *Report {
+	report := &Report{
+		ID:            r.ID,
+		ActionTaken:   r.ActionTaken,
+		Category:      string(r.Category),
+		Comment:       r.Comment,
+		Forwarded:     r.Forwarded,
+		CreatedAt:     r.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		StatusIDs:     []snowflake.ID{},
+		RuleIDs:       []string{},
+		TargetAccount: s.Account(r.Target),
+	}
+	if r.ActionTakenAt != nil {
+		at := r.ActionTakenAt.UTC().Format("2006-01-02T15:04:05.000Z")
+		report.ActionTakenAt = &at
+	}
+	for _, rs := range r.Statuses {
+		report.StatusIDs = append(report.StatusIDs, rs.StatusID)
+	}
+	return report
+}
+
 // Status is a representation of a Mastodon Status object.
 // https://docs.joinmastodon.org/entities/Status/
 type Status struct {
@@ -270,6 +310,7 @@ func (s *Serialiser) Status(st *models.Status) *Status {
 		Reblogged:        st.Reaction != nil && st.Reaction.Reblogged,
 		Muted:            st.Reaction != nil && st.Reaction.Muted,
 		Bookmarked:       st.Reaction != nil && st.Reaction.Bookmarked,
+		Pinned:           st.Reaction != nil && st.Reaction.Pinned,
 		Content:          st.Note(),
 		Reblog:           s.Status(st.Reblog),
 		Account:          s.Account(st.Actor),
@@ -278,7 +319,7 @@ func (s *Serialiser) Status(st *models.Status) *Status {
 		Tags:   s.Tags(st.Tag()),
 		Emojis: nil,
 		Card:   nil,
-		// Poll:             s.Poll(st.Poll),
+		Poll:   s.Poll(st.Poll),
 	}
 }
 
@@ -450,10 +491,50 @@ type Tag struct {
 	History []map[string]any `json:"history,omitempty"`
 }
 
+// https://docs.joinmastodon.org/entities/DomainBlock/
+type DomainBlock struct {
+	Domain   string `json:"domain"`
+	Digest   string `json:"digest"`
+	Severity string `json:"severity"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+func (s *Serialiser) DomainBlock(block *models.DomainBlock) *DomainBlock {
+	return &DomainBlock{
+		Domain:   block.Domain,
+		Digest:   fmt.Sprintf("%x", sha256.Sum256([]byte(block.Domain))),
+		Severity: "suspend",
+		Comment:  block.Comment,
+	}
+}
+
+// https://docs.joinmastodon.org/entities/FeaturedTag/
+type FeaturedTag struct {
+	ID            uint32  `json:"id,string"`
+	Name          string  `json:"name"`
+	URL           string  `json:"url"`
+	StatusesCount int64   `json:"statuses_count"`
+	LastStatusAt  *string `json:"last_status_at"`
+}
+
+func (s *Serialiser) FeaturedTag(ft *models.FeaturedTag) *FeaturedTag {
+	featured := &FeaturedTag{
+		ID:            ft.ID,
+		Name:          ft.Tag.Name,
+		URL:           s.urlFor("/tags/" + ft.Tag.Name),
+		StatusesCount: ft.StatusesCount,
+	}
+	if !ft.LastStatusAt.IsZero() {
+		lastStatusAt := ft.LastStatusAt.UTC().Format("2006-01-02")
+		featured.LastStatusAt = &lastStatusAt
+	}
+	return featured
+}
+
 // https://docs.joinmastodon.org/entities/Poll/
 type Poll struct {
 	ID          snowflake.ID `json:"id,string"`
-	ExpiresAt   string       `json:"expires_at"`
+	ExpiresAt   any          `json:"expires_at"` // string or null
 	Expired     bool         `json:"expired"`
 	Multiple    bool         `json:"multiple"`
 	VotesCount  int          `json:"votes_count"`
@@ -474,12 +555,14 @@ func (s *Serialiser) Poll(p *models.StatusPoll) *Poll {
 		return nil
 	}
 	poll := &Poll{
-		ID:         p.StatusID,
-		ExpiresAt:  p.ExpiresAt.Format("2006-01-02T15:04:05.006Z"),
-		Expired:    p.ExpiresAt.Before(time.Now()),
-		Multiple:   p.Multiple,
-		VotesCount: p.VotesCount,
-		Voted:      false,
+		ID:          p.StatusID,
+		Expired:     !p.ExpiresAt.IsZero() && p.ExpiresAt.Before(time.Now()),
+		Multiple:    p.Multiple,
+		VotesCount:  p.VotesCount,
+		VotersCount: &p.VotersCount,
+		Voted:       len(p.Votes) > 0,
+		OwnVotes:    []int{},
+		Emojis:      []any{},
 		Options: algorithms.Map(
 			p.Options,
 			func(option models.StatusPollOption) PollOption {
@@ -490,6 +573,16 @@ func (s *Serialiser) Poll(p *models.StatusPoll) *Poll {
 			},
 		),
 	}
+	if !p.ExpiresAt.IsZero() {
+		poll.ExpiresAt = p.ExpiresAt.UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+	for _, vote := range p.Votes {
+		for i, option := range p.Options {
+			if option.ID == vote.OptionID {
+				poll.OwnVotes = append(poll.OwnVotes, i)
+			}
+		}
+	}
 	return poll
 }
 
@@ -517,9 +610,39 @@ func (s *Serialiser) StatusEdit(st *models.Status) *StatusEdit {
 			}
 			return createdAt
 		}().Format("2006-01-02T15:04:05.006Z"),
-		Account: s.Account(st.Actor),
-		// Poll:             s.Poll(st.Poll),
-		// MediaAttachments: s.MediaAttachments(st.Attachments),
+		Account:          s.Account(st.Actor),
+		Poll:             s.Poll(st.Poll),
+		MediaAttachments: s.MediaAttachments(st.Attachments()),
+		Emojis:           []any{},
+	}
+}
+
+// StatusRevision returns the StatusEdit for a revision of the status.
+func (s *Serialiser) StatusRevision(st *models.Status, rev *models.StatusRevision) *StatusEdit {
+	return &StatusEdit{
+		Content:          rev.Content,
+		SpoilerText:      rev.Summary,
+		Sensitive:        rev.Sensitive,
+		CreatedAt:        rev.CreatedAt.UTC().Format("2006-01-02T15:04:05.006Z"),
+		Account:          s.Account(st.Actor),
+		Poll:             s.Poll(st.Poll),
+		MediaAttachments: s.MediaAttachments(rev.MediaAttachments()),
+		Emojis:           []any{},
+	}
+}
+
+// StatusSource is the plain text source of a status, used when editing it.
+type StatusSource struct {
+	ID          snowflake.ID `json:"id,string"`
+	Text        string       `json:"text"`
+	SpoilerText string       `json:"spoiler_text"`
+}
+
+func (s *Serialiser) StatusSource(st *models.Status) *StatusSource {
+	return &StatusSource{
+		ID:          st.ObjectID,
+		Text:        st.Source(),
+		SpoilerText: st.SpoilerText(),
 	}
 }
 
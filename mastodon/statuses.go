@@ -28,18 +28,44 @@ func StatusesCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
 		Visibility  string       `json:"visibility" schema:"visibility"`
 		Language    string       `json:"language" schema:"language"`
 		ScheduledAt *time.Time   `json:"scheduled_at,omitempty" schema:"scheduled_at"`
+		Poll        *struct {
+			Options   []string  `json:"options"`
+			ExpiresIn int       `json:"expires_in"`
+			Multiple  BoolOrBit `json:"multiple"`
+		} `json:"poll" schema:"-"`
+		// form encoded polls are flattened.
+		PollOptions   []string  `json:"-" schema:"poll[options][]"`
+		PollExpiresIn int       `json:"-" schema:"poll[expires_in]"`
+		PollMultiple  BoolOrBit `json:"-" schema:"poll[multiple]"`
 	}
 	if err := httpx.Params(r, &toot); err != nil {
 		return err
 	}
+	if toot.Poll != nil {
+		toot.PollOptions = toot.Poll.Options
+		toot.PollExpiresIn = toot.Poll.ExpiresIn
+		toot.PollMultiple = toot.Poll.Multiple
+	}
+	var poll *models.StatusPoll
+	if len(toot.PollOptions) > 0 {
+		if len(toot.PollOptions) < 2 || toot.PollExpiresIn < 300 {
+			return httpx.Error(http.StatusUnprocessableEntity, errors.New("a poll needs at least two options and must last at least five minutes"))
+		}
+		poll = &models.StatusPoll{
+			ExpiresAt: time.Now().Add(time.Duration(toot.PollExpiresIn) * time.Second),
+			Multiple:  bool(toot.PollMultiple),
+			Options: algorithms.Map(toot.PollOptions, func(title string) models.StatusPollOption {
+				return models.StatusPollOption{Title: title}
+			}),
+		}
+	}
 
 	var parent *models.Status
 	if toot.InReplyToID != 0 {
-		var st models.Status
-		if err := env.DB.Preload("Conversation").Take(&st, toot.InReplyToID).Error; err != nil {
+		parent, err = models.NewStatuses(env.DB).FindByID(toot.InReplyToID)
+		if err != nil {
 			return httpx.Error(http.StatusBadRequest, err)
 		}
-		parent = &st
 	}
 
 	status, err := models.NewStatuses(env.DB).Create(
@@ -50,6 +76,7 @@ func StatusesCreate(env *Env, w http.ResponseWriter, r *http.Request) error {
 		toot.SpoilerText,
 		toot.Language,
 		toot.Status,
+		poll,
 	)
 	if err != nil {
 		return err
@@ -92,12 +119,12 @@ func StatusesReblogDestroy(env *Env, w http.ResponseWriter, r *http.Request) err
 		return err
 	}
 
-	unblogged, err := models.NewReactions(env.DB).Unreblog(&status, user.Actor)
-	if err != nil {
+	if _, err := models.NewReactions(env.DB).Unreblog(&status, user.Actor); err != nil {
 		return err
 	}
+	// the reblog has been deleted, return the original status.
 	serialise := Serialiser{req: r}
-	return to.JSON(w, serialise.Status(unblogged))
+	return to.JSON(w, serialise.Status(&status))
 }
 
 func StatusesDestroy(env *Env, w http.ResponseWriter, r *http.Request) error {
@@ -105,22 +132,15 @@ func StatusesDestroy(env *Env, w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	actor := account.Actor
-	var status models.Status
-	if err := env.DB.Joins("Actor").Take(&status, chi.URLParam(r, "id")).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return httpx.Error(http.StatusNotFound, err)
-		}
+	status, err := findOwnStatus(env, account.Actor, r)
+	if err != nil {
 		return err
 	}
-	if status.ActorID != actor.ObjectID {
-		return httpx.Error(http.StatusForbidden, errors.New("forbidden"))
-	}
-	if err := env.DB.Delete(&status).Error; err != nil {
+	if err := models.NewStatuses(env.DB).Delete(status); err != nil {
 		return err
 	}
 	serialise := Serialiser{req: r}
-	return to.JSON(w, serialise.Status(&status))
+	return to.JSON(w, serialise.Status(status))
 }
 
 func StatusesShow(env *Env, w http.ResponseWriter, r *http.Request) error {
@@ -157,8 +177,79 @@ func StatusesHistoryShow(env *Env, w http.ResponseWriter, r *http.Request) error
 		}
 		return err
 	}
+	revisions, err := models.NewStatuses(env.DB).Revisions(&status)
+	if err != nil {
+		return err
+	}
 	serialise := Serialiser{req: r}
-	return to.JSON(w, []any{serialise.StatusEdit(&status)})
+	if len(revisions) == 0 {
+		// the status has not been edited.
+		return to.JSON(w, []any{serialise.StatusEdit(&status)})
+	}
+	return to.JSON(w, algorithms.Map(revisions, func(rev *models.StatusRevision) *StatusEdit {
+		return serialise.StatusRevision(&status, rev)
+	}))
+}
+
+func StatusesUpdate(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	var toot struct {
+		Status      string `json:"status" schema:"status,required"`
+		Sensitive   bool   `json:"sensitive" schema:"sensitive"`
+		SpoilerText string `json:"spoiler_text" schema:"spoiler_text"`
+		Language    string `json:"language" schema:"language"`
+	}
+	if err := httpx.Params(r, &toot); err != nil {
+		return err
+	}
+	status, err := findOwnStatus(env, user.Actor, r)
+	if err != nil {
+		return err
+	}
+	status, err = models.NewStatuses(env.DB).Edit(status, toot.Sensitive, toot.SpoilerText, toot.Language, toot.Status)
+	if err != nil {
+		if errors.Is(err, models.ErrReblogNotEditable) {
+			return httpx.Error(http.StatusUnprocessableEntity, err)
+		}
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.Status(status))
+}
+
+func StatusesSourceShow(env *Env, w http.ResponseWriter, r *http.Request) error {
+	user, err := env.authenticate(r)
+	if err != nil {
+		return err
+	}
+	status, err := findOwnStatus(env, user.Actor, r)
+	if err != nil {
+		return err
+	}
+	serialise := Serialiser{req: r}
+	return to.JSON(w, serialise.StatusSource(status))
+}
+
+// findOwnStatus returns the status named in the request if it was written by the actor.
+func findOwnStatus(env *Env, actor *models.Actor, r *http.Request) (*models.Status, error) {
+	id, err := snowflake.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return nil, httpx.Error(http.StatusBadRequest, err)
+	}
+	status, err := models.NewStatuses(env.DB).FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, httpx.Error(http.StatusNotFound, err)
+		}
+		return nil, err
+	}
+	if status.ActorID != actor.ObjectID {
+		return nil, httpx.Error(http.StatusForbidden, errors.New("forbidden"))
+	}
+	return status, nil
 }
 
 func StatusesFavouritesShow(env *Env, w http.ResponseWriter, r *http.Request) error {
@@ -230,6 +321,16 @@ func StatusesContextsShow(env *Env, w http.ResponseWriter, r *http.Request) erro
 	}
 
 	ancestors, descendants := thread(status.ObjectID, statuses)
+	root := &status
+	if len(ancestors) > 0 {
+		root = ancestors[0]
+	}
+	if root.Actor.IsRemote() {
+		// replies we have not seen will appear in later requests.
+		if err := models.NewStatuses(env.DB).RefreshReplies(root); err != nil {
+			return err
+		}
+	}
 	serialise := Serialiser{req: r}
 	return to.JSON(w, struct {
 		Ancestors   []*Status `json:"ancestors"`
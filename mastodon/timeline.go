@@ -25,6 +25,7 @@ func TimelinesHome(env *Env, w http.ResponseWriter, r *http.Request) error {
 		Where("(actor_id IN (?) AND in_reply_to_actor_id is null) or (actor_id in (?) and in_reply_to_actor_id IN (?))", following, following, following)
 	query := scope.Preload("Reaction", "actor_id = ?", user.Actor.ObjectID) // reactions
 	query = query.Preload("Reblog.Reaction", "actor_id = ?", user.Actor.ObjectID)
+	query = query.Preload("Poll.Votes", "actor_id = ?", user.Actor.ObjectID).Preload("Reblog.Poll.Votes", "actor_id = ?", user.Actor.ObjectID)
 	if err := query.Find(&statuses).Error; err != nil {
 		return httpx.Error(http.StatusInternalServerError, err)
 	}
@@ -48,6 +49,7 @@ func TimelinesPublic(env *Env, w http.ResponseWriter, r *http.Request) error {
 	if authenticated {
 		query = query.Preload("Reaction", "actor_id = ?", user.Actor.ObjectID) // reactions
 		query = query.Preload("Reblog.Reaction", "actor_id = ?", user.Actor.ObjectID)
+		query = query.Preload("Poll.Votes", "actor_id = ?", user.Actor.ObjectID).Preload("Reblog.Poll.Votes", "actor_id = ?", user.Actor.ObjectID)
 	}
 	if err := query.Find(&statuses).Error; err != nil {
 		return httpx.Error(http.StatusInternalServerError, err)
@@ -91,6 +93,7 @@ func TimelinesListShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 	query := scope.Joins("Actor")
 	query = query.Preload("Reaction", "actor_id = ?", user.Actor.ObjectID) // reactions
 	query = query.Preload("Reblog.Reaction", "actor_id = ?", user.Actor.ObjectID)
+	query = query.Preload("Poll.Votes", "actor_id = ?", user.Actor.ObjectID).Preload("Reblog.Poll.Votes", "actor_id = ?", user.Actor.ObjectID)
 	if err := query.Find(&statuses).Error; err != nil {
 		return httpx.Error(http.StatusInternalServerError, err)
 	}
@@ -119,6 +122,7 @@ func TimelinesTagShow(env *Env, w http.ResponseWriter, r *http.Request) error {
 	query = query.Scopes(models.PreloadStatus)
 	query = query.Preload("Reaction", "actor_id = ?", user.Actor.ObjectID) // reactions
 	query = query.Preload("Reblog.Reaction", "actor_id = ?", user.Actor.ObjectID)
+	query = query.Preload("Poll.Votes", "actor_id = ?", user.Actor.ObjectID).Preload("Reblog.Poll.Votes", "actor_id = ?", user.Actor.ObjectID)
 	if err := query.Find(&statuses).Error; err != nil {
 		return httpx.Error(http.StatusInternalServerError, err)
 	}
@@ -25,8 +25,12 @@ type Account struct {
 	Email             string          `gorm:"size:64;not null"`
 	EncryptedPassword []byte          `gorm:"size:60;not null"`
 	PrivateKey        []byte          `gorm:"not null"`
+	// Ed25519PrivateKey is the account's additional Ed25519 key, if it has one.
+	Ed25519PrivateKey []byte
 	RoleID            uint32
 	Role              *AccountRole
+	// HideCollections hides the account's followers and following from everyone else.
+	HideCollections bool `gorm:"not null;default:false"`
 }
 
 func (a *Account) Name() string {
@@ -108,6 +112,14 @@ func (a *Accounts) AccountForActor(actor *Actor) (*Account, error) {
 	return &account, nil
 }
 
+// HidesCollections reports whether the actor is a local account which hides
+// its followers and following from everyone else.
+func (a *Accounts) HidesCollections(actorID snowflake.ID) (bool, error) {
+	var count int64
+	err := a.db.Model(&Account{}).Where("actor_id = ? and hide_collections = true", actorID).Count(&count).Error
+	return count > 0, err
+}
+
 func (a *Accounts) Create(instance *Instance, name, email, password string) (*Account, error) {
 	var account Account
 	err := a.db.Transaction(func(tx *gorm.DB) error {
@@ -130,8 +142,8 @@ func (a *Accounts) Create(instance *Instance, name, email, password string) (*Ac
 				"preferredUsername": name,
 				"displayName":       name,
 				"publicKey": map[string]any{
-					"id":           "https://" + instance.Domain + "/u/admin#main-key",
-					"owner":        "https://" + instance.Domain + "/u/admin",
+					"id":           "https://" + instance.Domain + "/u/" + name + "#main-key",
+					"owner":        "https://" + instance.Domain + "/u/" + name,
 					"publicKeyPem": string(keypair.PublicKey),
 				},
 			},
@@ -143,6 +155,10 @@ func (a *Accounts) Create(instance *Instance, name, email, password string) (*Ac
 		if err != nil {
 			return err
 		}
+		actor.Type = "LocalPerson"
+		if err := tx.Model(actor).UpdateColumn("type", actor.Type).Error; err != nil {
+			return err
+		}
 
 		account = Account{
 			ID:                snowflake.Now(),
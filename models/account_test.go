@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/ed25519"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -70,4 +72,186 @@ func TestAccounts(t *testing.T) {
 		require.NoError(err)
 		require.NotNil(actor)
 	})
+
+	t.Run("hide collections", func(t *testing.T) {
+		require := require.New(t)
+
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		account, err := NewAccounts(tx).Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+
+		accounts := NewAccounts(tx)
+		hidden, err := accounts.HidesCollections(account.ActorID)
+		require.NoError(err)
+		require.False(hidden)
+
+		require.NoError(tx.Model(account).UpdateColumn("hide_collections", true).Error)
+		hidden, err = accounts.HidesCollections(account.ActorID)
+		require.NoError(err)
+		require.True(hidden)
+
+		// remote actors have no account to hide their collections
+		hidden, err = accounts.HidesCollections(bob.ObjectID)
+		require.NoError(err)
+		require.False(hidden)
+	})
+	t.Run("keys", func(t *testing.T) {
+		require := require.New(t)
+
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		accounts := NewAccounts(tx)
+		account, err := accounts.Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		require.Equal("https://example.com/u/alice#main-key", account.Actor.Object.Properties.PublicKey.ID)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		_, err = NewRelationships(tx).Follow(bob, account.Actor)
+		require.NoError(err)
+
+		key, err := account.Ed25519PrivKey()
+		require.NoError(err)
+		require.Nil(key)
+
+		require.NoError(accounts.AddEd25519Key(account))
+		key, err = account.Ed25519PrivKey()
+		require.NoError(err)
+		require.NotNil(key)
+
+		actor, err := NewActors(tx).FindByURI(account.Actor.URI())
+		require.NoError(err)
+		require.Len(actor.AssertionMethod(), 1)
+		require.Equal("Multikey", actor.AssertionMethod()[0].Type)
+
+		// keys are picked by their id
+		pub, err := actor.PublicKeyFor(account.Ed25519KeyID())
+		require.NoError(err)
+		require.True(key.Public().(ed25519.PublicKey).Equal(pub))
+
+		rsaKey, err := account.PublicKey()
+		require.NoError(err)
+		pub, err = actor.PublicKeyFor(actor.PublicKeyID())
+		require.NoError(err)
+		require.True(rsaKey.Equal(pub))
+
+		// the followers of the account are sent the new key
+		var count int64
+		require.NoError(tx.Model(&ActivitypubOutboxRequest{}).Where("object_id = ?", actor.ObjectID).Count(&count).Error)
+		require.NotZero(count)
+
+		// keys must be published by the actor under the id asked for
+		_, err = actor.PublicKeyFor(actor.URI() + "#other-key")
+		require.Error(err)
+		other := *actor.Object
+		other.Properties.PublicKey.Owner = "https://example.org/bob"
+		other.Properties.AssertionMethod = Multikeys{actor.AssertionMethod()[0]}
+		other.Properties.AssertionMethod[0].Controller = "https://example.org/bob"
+		impostor := &Actor{Object: &other}
+		_, err = impostor.PublicKeyFor(account.Ed25519KeyID())
+		require.ErrorContains(err, "controlled by")
+		_, err = impostor.PublicKeyFor(actor.PublicKeyID())
+		require.ErrorContains(err, "owned by")
+	})
+	t.Run("rotate keys", func(t *testing.T) {
+		require := require.New(t)
+
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		accounts := NewAccounts(tx)
+		account, err := accounts.Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		_, err = NewRelationships(tx).Follow(bob, account.Actor)
+		require.NoError(err)
+
+		oldID := account.Actor.PublicKeyID()
+		oldKey, err := account.PublicKey()
+		require.NoError(err)
+
+		require.NoError(accounts.RotateKeys(account, time.Hour))
+		newKey, err := account.PublicKey()
+		require.NoError(err)
+		require.False(oldKey.Equal(newKey))
+
+		actor, err := NewActors(tx).FindByURI(account.Actor.URI())
+		require.NoError(err)
+		require.NotEqual(oldID, actor.PublicKeyID())
+
+		// both keys verify during the grace period
+		pub, err := actor.PublicKeyFor(oldID)
+		require.NoError(err)
+		require.True(oldKey.Equal(pub))
+		pub, err = actor.PublicKeyFor(actor.PublicKeyID())
+		require.NoError(err)
+		require.True(newKey.Equal(pub))
+
+		// the followers of the account are sent the new key
+		var count int64
+		require.NoError(tx.Model(&ActivitypubOutboxRequest{}).Where("object_id = ?", actor.ObjectID).Count(&count).Error)
+		require.NotZero(count)
+
+		// retired keys are kept until they expire
+		require.NoError(accounts.ExpireRetiredKeys(time.Now()))
+		actor, err = NewActors(tx).FindByURI(account.Actor.URI())
+		require.NoError(err)
+		require.Len(actor.AssertionMethod(), 1)
+
+		require.NoError(accounts.ExpireRetiredKeys(time.Now().Add(2 * time.Hour)))
+		actor, err = NewActors(tx).FindByURI(account.Actor.URI())
+		require.NoError(err)
+		require.Empty(actor.AssertionMethod())
+		pub, err = actor.PublicKeyFor(actor.PublicKeyID())
+		require.NoError(err)
+		require.True(newKey.Equal(pub))
+		require.NoError(tx.Model(&AccountRetiredKey{}).Count(&count).Error)
+		require.Zero(count)
+	})
+	t.Run("rotate keys rotates the Ed25519 key", func(t *testing.T) {
+		require := require.New(t)
+
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		accounts := NewAccounts(tx)
+		account, err := accounts.Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		require.NoError(accounts.AddEd25519Key(account))
+
+		oldID := account.Ed25519KeyID()
+		oldKey, err := account.Ed25519PrivKey()
+		require.NoError(err)
+
+		require.NoError(accounts.RotateKeys(account, time.Hour))
+		newKey, err := account.Ed25519PrivKey()
+		require.NoError(err)
+		require.False(oldKey.Equal(newKey))
+		require.NotEqual(oldID, account.Ed25519KeyID())
+
+		// both keys verify during the grace period
+		actor, err := NewActors(tx).FindByURI(account.Actor.URI())
+		require.NoError(err)
+		pub, err := actor.PublicKeyFor(oldID)
+		require.NoError(err)
+		require.True(oldKey.Public().(ed25519.PublicKey).Equal(pub))
+		pub, err = actor.PublicKeyFor(account.Ed25519KeyID())
+		require.NoError(err)
+		require.True(newKey.Public().(ed25519.PublicKey).Equal(pub))
+
+		// only the new keys are published once the old ones expire
+		require.NoError(accounts.ExpireRetiredKeys(time.Now().Add(2 * time.Hour)))
+		actor, err = NewActors(tx).FindByURI(account.Actor.URI())
+		require.NoError(err)
+		require.Len(actor.AssertionMethod(), 1)
+		require.Equal(account.Ed25519KeyID(), actor.AssertionMethod()[0].ID)
+		_, err = actor.PublicKeyFor(oldID)
+		require.Error(err)
+	})
 }
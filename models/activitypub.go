@@ -1,6 +1,13 @@
 package models
 
-import "github.com/davecheney/pub/internal/snowflake"
+import (
+	"time"
+
+	"github.com/davecheney/pub/internal/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
 
 // activitypub support tables
 
@@ -17,14 +24,119 @@ type ActivitypubRefresh struct {
 }
 
 // ActivitypubOutboxRequest is a record of a request to send a status to an actor on a remote server.
+// ActivitypubOutboxRequests are created when a local actor creates, edits, or deletes a
+// status, updates their profile, or moves to another actor, and are processed by the
+// OutboxRequestProcessor in the background.
 type ActivitypubOutboxRequest struct {
 	Request
 
-	// StatusID is the ID of the status to send.
-	StatusID snowflake.ID `gorm:"not null"`
-	Status   *Status      `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	// ObjectID is the ID of the object to send, usually the Note of a status.
+	// For an update of their profile, or a move, it is the ID of the local actor.
+	ObjectID snowflake.ID `gorm:"not null"`
+	Object   *Object      `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
 
 	// ActorID is the ID of the remote actor to send the status to.
 	ActorID snowflake.ID `gorm:"not null"`
 	Actor   *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+
+	// Action is the action to perform; create, delete, update, or move.
+	Action ActivitypubOutboxRequestAction `gorm:"not null"`
+}
+
+type ActivitypubOutboxRequestAction string
+
+func (ActivitypubOutboxRequestAction) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres":
+		return "enum('create', 'delete', 'update', 'move')"
+	case "sqlite":
+		return "TEXT"
+	default:
+		return ""
+	}
+}
+
+// ActivitypubInboxRequest is a record of an activity delivered to the inbox.
+// ActivitypubInboxRequests are created when the signature of an incoming activity
+// has been verified, and are processed by the InboxRequestProcessor in the background.
+type ActivitypubInboxRequest struct {
+	Request
+
+	// ActorURI is the URI of the actor who sent the activity.
+	// Activities from the same actor are processed in the order they were received.
+	ActorURI string `gorm:"size:255;not null;index"`
+	// ActivityID is the id of the activity.
+	ActivityID string `gorm:"size:255;not null"`
+	// Activity is the activity as received.
+	Activity map[string]any `gorm:"serializer:json;not null"`
+
+	// State is the state of the request; pending, or dead if the request
+	// could not be processed after the maximum number of attempts.
+	State ActivitypubInboxRequestState `gorm:"not null;default:'pending'"`
+}
+
+type ActivitypubInboxRequestState string
+
+func (ActivitypubInboxRequestState) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres":
+		return "enum('pending', 'dead')"
+	case "sqlite":
+		return "TEXT"
+	default:
+		return ""
+	}
+}
+
+// PendingInboxRequests is a scope which selects the oldest pending inbox
+// request for each actor. A later request from an actor is not selected until
+// all of their earlier requests have been processed, or marked dead.
+func PendingInboxRequests(db *gorm.DB) *gorm.DB {
+	return db.Where("state = ?", "pending").
+		Where("NOT EXISTS (?)", db.Session(&gorm.Session{NewDB: true}).
+			Table("activitypub_inbox_requests AS earlier").
+			Select("1").
+			Where("earlier.actor_uri = activitypub_inbox_requests.actor_uri AND earlier.id < activitypub_inbox_requests.id AND earlier.state = ?", "pending")).
+		Order("id")
+}
+
+// ActivitypubSeenActivity is a record of the id of an activity delivered to the inbox.
+// The same activity is often delivered more than once, and later deliveries are discarded.
+type ActivitypubSeenActivity struct {
+	// ActivityID is the id of the activity.
+	ActivityID string `gorm:"primaryKey;size:255"`
+	// CreatedAt is the time the activity was first seen.
+	CreatedAt time.Time `gorm:"index"`
+}
+
+type SeenActivities struct {
+	db *gorm.DB
+}
+
+func NewSeenActivities(db *gorm.DB) *SeenActivities {
+	return &SeenActivities{db: db}
+}
+
+// Seen records the activity id, returning true if it was already seen within the ttl.
+func (s *SeenActivities) Seen(id string, ttl time.Duration) (bool, error) {
+	res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ActivitypubSeenActivity{ActivityID: id})
+	if err := res.Error; err != nil {
+		return false, err
+	}
+	if res.RowsAffected > 0 {
+		return false, nil
+	}
+	// the activity has been seen before; if the record has expired, but not
+	// yet been removed, treat the activity as unseen and restart the ttl.
+	now := time.Now()
+	res = s.db.Model(&ActivitypubSeenActivity{}).Where("activity_id = ? AND created_at < ?", id, now.Add(-ttl)).Update("created_at", now)
+	if err := res.Error; err != nil {
+		return false, err
+	}
+	return res.RowsAffected == 0, nil
+}
+
+// Expire removes records of activities seen before the given time.
+func (s *SeenActivities) Expire(before time.Time) error {
+	return s.db.Where("created_at < ?", before).Delete(&ActivitypubSeenActivity{}).Error
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingInboxRequests(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("oldest pending request for each actor", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		enqueue := func(actor, id string) *ActivitypubInboxRequest {
+			request := &ActivitypubInboxRequest{
+				ActorURI:   actor,
+				ActivityID: id,
+				Activity:   map[string]any{"id": id, "actor": actor, "type": "Like"},
+			}
+			require.NoError(tx.Create(request).Error)
+			return request
+		}
+		a1 := enqueue("https://example.com/alice", "https://example.com/alice#1")
+		enqueue("https://example.com/alice", "https://example.com/alice#2")
+		b1 := enqueue("https://example.org/bob", "https://example.org/bob#1")
+
+		pending := func() []string {
+			var requests []ActivitypubInboxRequest
+			require.NoError(tx.Scopes(PendingInboxRequests).Find(&requests).Error)
+			var ids []string
+			for _, r := range requests {
+				ids = append(ids, r.ActivityID)
+			}
+			return ids
+		}
+		require.Equal([]string{"https://example.com/alice#1", "https://example.org/bob#1"}, pending())
+
+		// a failed request holds back later requests from the same actor
+		require.NoError(tx.Model(a1).Update("attempts", 1).Error)
+		require.Equal([]string{"https://example.com/alice#1", "https://example.org/bob#1"}, pending())
+
+		// until it is marked dead
+		require.NoError(tx.Model(a1).Update("state", "dead").Error)
+		require.NoError(tx.Delete(b1).Error)
+		require.Equal([]string{"https://example.com/alice#2"}, pending())
+
+		var request ActivitypubInboxRequest
+		require.NoError(tx.First(&request, a1.ID).Error)
+		require.EqualValues("dead", request.State)
+		require.Equal("Like", request.Activity["type"])
+	})
+}
+
+func TestSeenActivities(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("an activity is seen once within the ttl", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		seen := NewSeenActivities(tx)
+		const id = "https://example.org/users/bob#likes/1"
+		ok, err := seen.Seen(id, time.Hour)
+		require.NoError(err)
+		require.False(ok)
+
+		ok, err = seen.Seen(id, time.Hour)
+		require.NoError(err)
+		require.True(ok)
+
+		// once the ttl has passed, the activity is treated as unseen
+		require.NoError(tx.Model(&ActivitypubSeenActivity{}).Where("activity_id = ?", id).Update("created_at", time.Now().Add(-2*time.Hour)).Error)
+		ok, err = seen.Seen(id, time.Hour)
+		require.NoError(err)
+		require.False(ok)
+
+		ok, err = seen.Seen(id, time.Hour)
+		require.NoError(err)
+		require.True(ok)
+	})
+
+	t.Run("Expire removes old records", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		seen := NewSeenActivities(tx)
+		_, err := seen.Seen("https://example.org/1", time.Hour)
+		require.NoError(err)
+		_, err = seen.Seen("https://example.org/2", time.Hour)
+		require.NoError(err)
+		require.NoError(tx.Model(&ActivitypubSeenActivity{}).Where("activity_id = ?", "https://example.org/1").Update("created_at", time.Now().Add(-2*time.Hour)).Error)
+
+		require.NoError(seen.Expire(time.Now().Add(-time.Hour)))
+
+		var ids []string
+		require.NoError(tx.Model(&ActivitypubSeenActivity{}).Pluck("activity_id", &ids).Error)
+		require.Equal([]string{"https://example.org/2"}, ids)
+	})
+}
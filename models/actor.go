@@ -1,9 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/davecheney/pub/internal/snowflake"
@@ -58,10 +61,26 @@ type ActorObject struct {
 			Owner        string `json:"owner"`
 			PublicKeyPem string `json:"publicKeyPem"`
 		} `json:"publicKey"`
-		Attachments []ActorAttachment `json:"attachment"`
+		AssertionMethod Multikeys         `json:"assertionMethod"`
+		Attachments     []ActorAttachment `json:"attachment"`
+		AlsoKnownAs     URIs              `json:"alsoKnownAs"`
+		MovedTo         string            `json:"movedTo"`
 	} `gorm:"serializer:json;not null"`
 }
 
+// URIs is a list of URIs which may be represented in JSON as either
+// a single string or an array of strings.
+type URIs []string
+
+func (u *URIs) UnmarshalJSON(b []byte) error {
+	var uri string
+	if err := json.Unmarshal(b, &uri); err == nil {
+		*u = URIs{uri}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(u))
+}
+
 func (ActorObject) TableName() string {
 	return "objects"
 }
@@ -107,6 +126,16 @@ func (a *Actor) InboxURL() string {
 	return a.Object.Properties.Inbox
 }
 
+// AlsoKnownAs returns the URIs of the actor's aliases.
+func (a *Actor) AlsoKnownAs() []string {
+	return a.Object.Properties.AlsoKnownAs
+}
+
+// MovedTo returns the URI of the actor this actor has moved to, if any.
+func (a *Actor) MovedTo() string {
+	return a.Object.Properties.MovedTo
+}
+
 func (a *Actor) Locked() bool {
 	return a.Object.Properties.ManuallyApprovesFollowers
 }
@@ -216,7 +245,12 @@ func (a *Actor) ActorType() string {
 	}
 }
 
+// PublicKeyID returns the id of the actor's publicKey. Keys published without an
+// id of their own, or under another actor's id, are assumed to be the actor's #main-key.
 func (a *Actor) PublicKeyID() string {
+	if id := a.Object.Properties.PublicKey.ID; strings.HasPrefix(id, a.URI()+"#") {
+		return id
+	}
 	return fmt.Sprintf("%s#main-key", a.URI())
 }
 
@@ -274,6 +308,233 @@ func (a *Actors) FindOrCreateByURI(uri string) (*Actor, error) {
 	return a.FindByURI(uri)
 }
 
+// Recipients returns the remote actors named in addressees, typically the to and cc
+// fields of an object or activity. Addressing the author's followers collection
+// expands to each of their followers. Actors which share an inbox are returned once.
+// Actors blocking, or blocked by, the author, and actors on blocked domains, are excluded.
+func (a *Actors) Recipients(author *Actor, addressees []any) ([]*Actor, error) {
+	var blocks []snowflake.ID
+	if err := a.db.Model(&Relationship{}).Where("actor_id = ? and (blocking = true or blocked_by = true)", author.ObjectID).Pluck("target_id", &blocks).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	blocked := make(map[snowflake.ID]bool)
+	for _, id := range blocks {
+		blocked[id] = true
+	}
+	var candidates []*Actor
+	for _, addressee := range addressees {
+		uri, _ := addressee.(string)
+		switch uri {
+		case "", "https://www.w3.org/ns/activitystreams#Public":
+			// not an actor
+		case author.URI() + "/followers":
+			var followers []*Relationship
+			if err := a.db.Scopes(PreloadRelationshipTarget).Where("actor_id = ? and followed_by = true", author.ObjectID).Find(&followers).Error; err != nil {
+				return nil, err
+			}
+			for _, rel := range followers {
+				candidates = append(candidates, rel.Target)
+			}
+		default:
+			actor, err := a.FindByURI(uri)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			candidates = append(candidates, actor)
+		}
+	}
+	var recipients []*Actor
+	domainBlocked := make(map[string]bool)
+	for _, candidate := range candidates {
+		if candidate.IsLocal() || blocked[candidate.ObjectID] {
+			continue
+		}
+		isBlocked, ok := domainBlocked[candidate.Domain]
+		if !ok {
+			var err error
+			isBlocked, err = NewDomainBlocks(a.db).IsBlocked(candidate.Domain)
+			if err != nil {
+				return nil, err
+			}
+			domainBlocked[candidate.Domain] = isBlocked
+		}
+		if isBlocked {
+			continue
+		}
+		inbox := candidate.Inbox()
+		if inbox == "" || seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		recipients = append(recipients, candidate)
+	}
+	return recipients, nil
+}
+
+// Fetch returns the actor with the given URI. Remote actors are refreshed from
+// their origin before they are returned, recording the time of the refresh as
+// the actor's UpdatedAt.
+func (a *Actors) Fetch(uri string) (*Actor, error) {
+	actor, err := a.FindByURI(uri)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return a.FindOrCreateByURI(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if actor.IsLocal() {
+		return actor, nil
+	}
+	props, err := fetchObject(a.db.Statement.Context, uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.saveProperties(actor, props); err != nil {
+		return nil, err
+	}
+	if err := a.db.Model(actor).UpdateColumn("updated_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return a.FindByURI(uri)
+}
+
+// SetAliases replaces the alsoKnownAs aliases of the local actor.
+func (a *Actors) SetAliases(actor *Actor, aliases []string) error {
+	if actor.IsRemote() {
+		return fmt.Errorf("cannot set aliases of remote actor %s", actor.URI())
+	}
+	return a.updateProperties(actor, map[string]any{
+		"alsoKnownAs": aliases,
+	})
+}
+
+// Update merges props into the properties of the local actor, and schedules an
+// Update activity to be sent to each of the actor's followers.
+func (a *Actors) Update(actor *Actor, props map[string]any) error {
+	if actor.IsRemote() {
+		return fmt.Errorf("cannot update remote actor %s", actor.URI())
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewActors(tx).updateProperties(actor, props); err != nil {
+			return err
+		}
+		return enqueueActorActivity(tx, actor, "update")
+	})
+}
+
+// FormatNote converts the plain text note of the author's profile into HTML,
+// linking mentions of known actors and hashtags as in the content of a status.
+func (a *Actors) FormatNote(author *Actor, note string) string {
+	content, _, _ := formatNote(a, author, note)
+	return content
+}
+
+// Move records that the local actor has moved to the target, and schedules a
+// Move activity to be sent to each of the actor's followers. The target must
+// list the actor in its alsoKnownAs aliases.
+func (a *Actors) Move(actor, target *Actor) error {
+	if actor.IsRemote() {
+		return fmt.Errorf("cannot move remote actor %s", actor.URI())
+	}
+	if !slices.Contains(target.AlsoKnownAs(), actor.URI()) {
+		return fmt.Errorf("%s does not list %s as an alias", target.URI(), actor.URI())
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewActors(tx).updateProperties(actor, map[string]any{
+			"movedTo": target.URI(),
+		}); err != nil {
+			return err
+		}
+		return enqueueActorActivity(tx, actor, "move")
+	})
+}
+
+// enqueueActorActivity schedules delivery of an activity about the local actor
+// to each of the actor's followers.
+func enqueueActorActivity(tx *gorm.DB, actor *Actor, action ActivitypubOutboxRequestAction) error {
+	recipients, err := NewActors(tx).Recipients(actor, []any{actor.URI() + "/followers"})
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := tx.Create(&ActivitypubOutboxRequest{
+			ObjectID: actor.ObjectID,
+			ActorID:  recipient.ObjectID,
+			Action:   action,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Moved records that the remote actor has moved to the target, and moves the
+// follows of local actors from the actor to the target. The target must list
+// the actor in its alsoKnownAs aliases.
+func (a *Actors) Moved(actor, target *Actor) error {
+	if !slices.Contains(target.AlsoKnownAs(), actor.URI()) {
+		return fmt.Errorf("%s does not list %s as an alias", target.URI(), actor.URI())
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewActors(tx).updateProperties(actor, map[string]any{
+			"movedTo": target.URI(),
+		}); err != nil {
+			return err
+		}
+		var follows []*Relationship
+		query := tx.Preload("Actor").Preload("Actor.Object").
+			Joins("JOIN actors ON actors.object_id = relationships.actor_id AND actors.type IN ?", []string{"LocalPerson", "LocalService"})
+		if err := query.Where("relationships.target_id = ? AND (relationships.following = true OR relationships.requested = true)", actor.ObjectID).Find(&follows).Error; err != nil {
+			return err
+		}
+		relationships := NewRelationships(tx)
+		for _, follow := range follows {
+			if _, err := relationships.Unfollow(follow.Actor, actor); err != nil {
+				return err
+			}
+			if _, err := relationships.FollowOrRequest(follow.Actor, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// updateProperties merges props into the properties of the actor's object.
+// A nil value removes the property.
+func (a *Actors) updateProperties(actor *Actor, props map[string]any) error {
+	var obj Object
+	if err := a.db.Take(&obj, actor.ObjectID).Error; err != nil {
+		return err
+	}
+	for k, v := range props {
+		if v == nil {
+			delete(obj.Properties, k)
+			continue
+		}
+		obj.Properties[k] = v
+	}
+	return a.saveProperties(actor, obj.Properties)
+}
+
+// saveProperties replaces the properties of the actor's object.
+// Object hooks are not run, so the actor's counts and local type are preserved.
+func (a *Actors) saveProperties(actor *Actor, props map[string]any) error {
+	if err := a.db.Model(&Object{ID: actor.ObjectID}).Select("properties").UpdateColumns(&Object{Properties: props}).Error; err != nil {
+		return err
+	}
+	var obj ActorObject
+	if err := a.db.Take(&obj, actor.ObjectID).Error; err != nil {
+		return err
+	}
+	actor.Object = &obj
+	return nil
+}
+
 // Refesh schedules a refresh of an actor's data.
 func (a *Actors) Refresh(actor *Actor) error {
 	db := a.db.Clauses(clause.OnConflict{
@@ -282,11 +543,26 @@ func (a *Actors) Refresh(actor *Actor) error {
 			"created_at",
 			"updated_at",
 			"attempts", // resets the attempts counter
+			"next_attempt_at",
 		}),
 	})
 	return db.Create(&ActorRefreshRequest{ActorID: actor.ObjectID}).Error
 }
 
+// Backfill schedules a fetch of the recent posts in the actor's outbox.
+func (a *Actors) Backfill(actor *Actor) error {
+	db := a.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "actor_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"created_at",
+			"updated_at",
+			"attempts", // resets the attempts counter
+			"next_attempt_at",
+		}),
+	})
+	return db.Create(&ActorBackfillRequest{ActorID: actor.ObjectID}).Error
+}
+
 type Request struct {
 	ID uint32 `gorm:"primarykey;"`
 	// CreatedAt is the time the request was created.
@@ -299,6 +575,22 @@ type Request struct {
 	LastAttempt time.Time
 	// LastResult is the result of the last attempt if it failed.
 	LastResult string `gorm:"type:text;"`
+	// NextAttemptAt is the earliest time the request will be attempted. It is the
+	// time the request was created until the request fails.
+	NextAttemptAt time.Time `gorm:"index"`
+}
+
+// BeforeCreate schedules the first attempt of the request.
+func (r *Request) BeforeCreate(tx *gorm.DB) error {
+	if r.NextAttemptAt.IsZero() {
+		r.NextAttemptAt = time.Now()
+	}
+	return nil
+}
+
+// GetRequest returns the Request embedded in a request record.
+func (r *Request) GetRequest() *Request {
+	return r
 }
 
 // ActorRefreshRequest is a request to refresh an actor's data.
@@ -310,6 +602,16 @@ type ActorRefreshRequest struct {
 	Actor *Actor `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
 }
 
+// ActorBackfillRequest is a request to fetch the recent posts from a remote actor's outbox,
+// made when a local actor starts following them.
+type ActorBackfillRequest struct {
+	Request
+	// ActorID is the ID of the actor whose outbox is fetched.
+	ActorID snowflake.ID `gorm:"uniqueIndex;not null;"`
+	// Actor is the actor whose outbox is fetched.
+	Actor *Actor `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+}
+
 // MaybeExcludeReplies returns a query that excludes replies if the request contains
 // the exclude_replies parameter.
 func MaybeExcludeReplies(r *http.Request) func(db *gorm.DB) *gorm.DB {
@@ -32,4 +32,104 @@ func TestActors(t *testing.T) {
 		require.NoError(tx.Model(&ActorRefreshRequest{ActorID: alice.ObjectID}).Count(&count).Error)
 		require.Equal(int64(1), count)
 	})
+
+	t.Run("Move notifies followers", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		target := MockActor(t, tx, "alice", "example.org", RemoteActor)
+		_, err := NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+
+		actors := NewActors(tx)
+		err = actors.Move(alice, target)
+		require.Error(err, "target does not list alice as an alias")
+
+		require.NoError(actors.updateProperties(target, map[string]any{"alsoKnownAs": alice.URI()}))
+		require.Equal([]string{alice.URI()}, target.AlsoKnownAs())
+		require.NoError(actors.Move(alice, target))
+		require.Equal(target.URI(), alice.MovedTo())
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ?", alice.ObjectID).Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(bob.ObjectID, requests[0].ActorID)
+		require.EqualValues("move", requests[0].Action)
+
+		// alice remains local
+		alice, err = actors.FindByURI(alice.URI())
+		require.NoError(err)
+		require.True(alice.IsLocal())
+	})
+
+	t.Run("Moved moves local follows", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		target := MockActor(t, tx, "bob", "example.net", RemoteActor)
+		relationships := NewRelationships(tx)
+		_, err := relationships.Follow(alice, bob)
+		require.NoError(err)
+
+		actors := NewActors(tx)
+		require.NoError(actors.updateProperties(target, map[string]any{"alsoKnownAs": []any{bob.URI()}}))
+		require.NoError(actors.Moved(bob, target))
+		require.Equal(target.URI(), bob.MovedTo())
+
+		rel, err := relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.False(rel.Following)
+
+		rel, err = relationships.findOrCreate(alice, target)
+		require.NoError(err)
+		require.True(rel.Requested)
+	})
+
+	t.Run("Update notifies followers", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		_, err := NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+
+		actors := NewActors(tx)
+		require.NoError(actors.Update(alice, map[string]any{
+			"name":    "Alice",
+			"summary": "<p>hello</p>",
+		}))
+		require.Equal("Alice", alice.DisplayName())
+		require.Equal("<p>hello</p>", alice.Note())
+		require.Equal("alice", alice.Object.Properties.PreferredUsername)
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ?", alice.ObjectID).Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(bob.ObjectID, requests[0].ActorID)
+		require.EqualValues("update", requests[0].Action)
+
+		err = actors.Update(bob, map[string]any{"name": "Bob"})
+		require.Error(err, "remote actors cannot be updated")
+	})
+
+	t.Run("FormatNote", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		actors := NewActors(tx)
+		require.Equal("", actors.FormatNote(alice, ""))
+		require.Equal("<p>Hello &lt;world&gt;</p>", actors.FormatNote(alice, "Hello <world>"))
+		require.Equal("<p>one<br />two</p><p>three</p>", actors.FormatNote(alice, "one\ntwo\n\n\n\nthree\n"))
+		require.Equal(`<p>I like <a href="https://example.com/tags/go" class="mention hashtag" rel="tag">#<span>Go</span></a></p>`, actors.FormatNote(alice, "I like #Go"))
+	})
 }
@@ -3,21 +3,23 @@ package models
 // AllTables returns a slice of all tables in the database.
 func AllTables() []interface{} {
 	return []interface{}{
-		&ActivitypubRefresh{}, &ActivitypubOutboxRequest{},
-		&Actor{}, &ActorRefreshRequest{},
-		&Account{}, &AccountList{}, &AccountListMember{}, &AccountRole{}, &AccountMarker{}, &AccountPreferences{},
+		&ActivitypubRefresh{}, &ActivitypubInboxRequest{}, &ActivitypubOutboxRequest{}, &ActivitypubSeenActivity{},
+		&Actor{}, &ActorRefreshRequest{}, &ActorBackfillRequest{},
+		&Account{}, &AccountList{}, &AccountRetiredKey{}, &AccountListMember{}, &AccountRole{}, &AccountMarker{}, &AccountPreferences{},
 		&Application{},
 		&Conversation{},
+		&DomainBlock{},
 		&Instance{}, &InstanceRule{},
 		&Object{},
 		&Peer{},
 		&PushSubscription{},
 		&Reaction{}, &ReactionRequest{},
 		&Relationship{}, &RelationshipRequest{},
+		&Report{}, &ReportStatus{}, &ReportRequest{},
 		// &Notification{},
-		&Status{}, &StatusPoll{}, &StatusPollOption{}, &StatusMention{},
+		&Status{}, &StatusPoll{}, &StatusPollOption{}, &StatusPollVote{}, &StatusPollVoteRequest{}, &StatusMention{}, &StatusRevision{}, &StatusRepliesRequest{},
 		// &StatusAttachmentRequest{},
-		&Tag{},
+		&Tag{}, &FeaturedTag{},
 		&Token{},
 	}
 }
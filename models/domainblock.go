@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A DomainBlock suspends federation with a remote domain, and its subdomains.
+type DomainBlock struct {
+	ID        uint32 `gorm:"primarykey"`
+	CreatedAt time.Time
+	Domain    string `gorm:"size:255;not null;uniqueIndex"`
+	// Comment is the reason for the block, shown publicly.
+	Comment string `gorm:"size:255"`
+}
+
+type DomainBlocks struct {
+	db *gorm.DB
+}
+
+func NewDomainBlocks(db *gorm.DB) *DomainBlocks {
+	return &DomainBlocks{db: db}
+}
+
+// Create blocks the domain. Blocking a domain which is already blocked updates
+// the comment.
+func (d *DomainBlocks) Create(domain, comment string) (*DomainBlock, error) {
+	block := &DomainBlock{
+		Domain:  strings.ToLower(domain),
+		Comment: comment,
+	}
+	err := d.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "domain"}},
+		DoUpdates: clause.AssignmentColumns([]string{"comment"}),
+	}).Create(block).Error
+	return block, err
+}
+
+// Delete unblocks the domain.
+func (d *DomainBlocks) Delete(domain string) error {
+	return d.db.Where("domain = ?", strings.ToLower(domain)).Delete(&DomainBlock{}).Error
+}
+
+// FindAll returns all the blocked domains.
+func (d *DomainBlocks) FindAll() ([]*DomainBlock, error) {
+	var blocks []*DomainBlock
+	return blocks, d.db.Order("domain").Find(&blocks).Error
+}
+
+// IsBlocked returns true if the domain, or any of its parent domains, is blocked.
+func (d *DomainBlocks) IsBlocked(domain string) (bool, error) {
+	var domains []string
+	for domain = strings.ToLower(domain); domain != ""; {
+		domains = append(domains, domain)
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		domain = parent
+	}
+	if len(domains) == 0 {
+		return false, nil
+	}
+	var count int64
+	err := d.db.Model(&DomainBlock{}).Where("domain IN ?", domains).Count(&count).Error
+	return count > 0, err
+}
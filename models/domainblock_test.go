@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainBlocks(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("IsBlocked", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		blocks := NewDomainBlocks(tx)
+		_, err := blocks.Create("Example.org", "spam")
+		require.NoError(err)
+
+		for domain, want := range map[string]bool{
+			"example.org":            true,
+			"social.example.org":     true,
+			"EXAMPLE.ORG":            true,
+			"example.com":            false,
+			"notexample.org":         false,
+			"org":                    false,
+			"":                       false,
+			"a.b.social.example.org": true,
+		} {
+			blocked, err := blocks.IsBlocked(domain)
+			require.NoError(err)
+			require.Equal(want, blocked, domain)
+		}
+
+		// blocking again updates the comment
+		_, err = blocks.Create("example.org", "abuse")
+		require.NoError(err)
+		all, err := blocks.FindAll()
+		require.NoError(err)
+		require.Len(all, 1)
+		require.Equal("abuse", all[0].Comment)
+
+		require.NoError(blocks.Delete("example.org"))
+		blocked, err := blocks.IsBlocked("example.org")
+		require.NoError(err)
+		require.False(blocked)
+	})
+
+	t.Run("Recipients on blocked domains are excluded", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		carol := MockActor(t, tx, "carol", "social.example.net", RemoteActor)
+
+		_, err := NewDomainBlocks(tx).Create("example.net", "spam")
+		require.NoError(err)
+
+		recipients, err := NewActors(tx).Recipients(alice, []any{bob.URI(), carol.URI()})
+		require.NoError(err)
+		require.Len(recipients, 1)
+		require.Equal(bob.ObjectID, recipients[0].ObjectID)
+	})
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+	stdcrypto "crypto"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/davecheney/pub/internal/crypto"
+	"github.com/davecheney/pub/internal/snowflake"
+	"gorm.io/gorm"
+)
+
+// A Multikey is a public key published in an actor's assertionMethod, as
+// described by FEP-521a.
+type Multikey struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+}
+
+// Multikeys is a list of Multikeys which may be represented in JSON as either a
+// single object or an array. Entries which are not objects are ignored.
+type Multikeys []Multikey
+
+func (m *Multikeys) UnmarshalJSON(b []byte) error {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(b, &entries); err != nil {
+		entries = []json.RawMessage{b}
+	}
+	var keys Multikeys
+	for _, entry := range entries {
+		var key Multikey
+		if err := json.Unmarshal(entry, &key); err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	*m = keys
+	return nil
+}
+
+// PublicKeyFor returns the actor's public key with the given id; one of the
+// Multikeys of the actor's assertionMethod, which the actor must control, or the
+// key published as the actor's publicKey, which the actor must own.
+func (a *Actor) PublicKeyFor(keyID string) (stdcrypto.PublicKey, error) {
+	for _, key := range a.Object.Properties.AssertionMethod {
+		if key.ID != keyID {
+			continue
+		}
+		if key.Type != "Multikey" {
+			return nil, fmt.Errorf("actor %s key %s has unsupported type %q", a.URI(), keyID, key.Type)
+		}
+		if key.Controller != a.URI() {
+			return nil, fmt.Errorf("actor %s key %s is controlled by %q", a.URI(), keyID, key.Controller)
+		}
+		return crypto.DecodeMultikey(key.PublicKeyMultibase)
+	}
+	publicKey := a.Object.Properties.PublicKey
+	if publicKey.ID != keyID || len(publicKey.PublicKeyPem) == 0 {
+		return nil, fmt.Errorf("actor %s has no key %s", a.URI(), keyID)
+	}
+	if publicKey.Owner != a.URI() {
+		return nil, fmt.Errorf("actor %s key %s is owned by %q", a.URI(), keyID, publicKey.Owner)
+	}
+	return crypto.ParsePublicKey([]byte(publicKey.PublicKeyPem))
+}
+
+// AssertionMethod returns the Multikeys the actor publishes.
+func (a *Actor) AssertionMethod() []Multikey {
+	return a.Object.Properties.AssertionMethod
+}
+
+// Ed25519KeyID returns the id of the account's Ed25519 key; the id of the Multikey
+// which publishes it, as rotated keys have ids of their own, otherwise #ed25519-key.
+func (a *Account) Ed25519KeyID() string {
+	if key, err := a.Ed25519PrivKey(); err == nil && key != nil {
+		for _, mk := range a.Actor.AssertionMethod() {
+			pub, err := crypto.DecodeMultikey(mk.PublicKeyMultibase)
+			if err == nil && key.Public().(ed25519.PublicKey).Equal(pub) {
+				return mk.ID
+			}
+		}
+	}
+	return a.Actor.URI() + "#ed25519-key"
+}
+
+// Ed25519PrivKey returns the account's Ed25519 private key, or nil if the
+// account does not have one.
+func (a *Account) Ed25519PrivKey() (ed25519.PrivateKey, error) {
+	if len(a.Ed25519PrivateKey) == 0 {
+		return nil, nil
+	}
+	return crypto.ParseEd25519PrivateKey(a.Ed25519PrivateKey)
+}
+
+// AddEd25519Key generates an Ed25519 key for the local account, replacing any it
+// already has, and publishes it in the assertionMethod of the account's actor.
+func (a *Accounts) AddEd25519Key(account *Account) error {
+	keypair, err := crypto.GenerateEd25519Keypair()
+	if err != nil {
+		return err
+	}
+	privateKey, err := crypto.ParseEd25519PrivateKey(keypair.PrivateKey)
+	if err != nil {
+		return err
+	}
+	multibase, err := crypto.EncodeMultikey(privateKey.Public())
+	if err != nil {
+		return err
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(account).UpdateColumn("ed25519_private_key", keypair.PrivateKey).Error; err != nil {
+			return err
+		}
+		account.Ed25519PrivateKey = keypair.PrivateKey
+		keys := []Multikey{{
+			ID:                 account.Ed25519KeyID(),
+			Type:               "Multikey",
+			Controller:         account.Actor.URI(),
+			PublicKeyMultibase: multibase,
+		}}
+		for _, key := range account.Actor.AssertionMethod() {
+			if key.ID != account.Ed25519KeyID() {
+				keys = append(keys, key)
+			}
+		}
+		return NewActors(tx).Update(account.Actor, map[string]any{
+			"assertionMethod": keys,
+		})
+	})
+}
+
+// An AccountRetiredKey is a public key a local account has rotated away from.
+// The key remains published in the assertionMethod of the account's actor until
+// it expires, so that requests signed before the rotation can still be verified.
+type AccountRetiredKey struct {
+	ID        uint32       `gorm:"primarykey"`
+	AccountID snowflake.ID `gorm:"not null;index"`
+	Account   *Account     `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	KeyID     string       `gorm:"size:255;not null"`
+	ExpiresAt time.Time    `gorm:"not null;index"`
+}
+
+// RotateKeys replaces the RSA keypair of the local account, and its Ed25519 key if
+// it has one. The old public keys remain published, under their old ids, for the
+// grace period. The account's followers are sent an Update of the actor so they
+// refetch its keys.
+func (a *Accounts) RotateKeys(account *Account, grace time.Duration) error {
+	keypair, err := crypto.GenerateRSAKeypair()
+	if err != nil {
+		return err
+	}
+	oldKey, err := account.PublicKey()
+	if err != nil {
+		return err
+	}
+	multibase, err := crypto.EncodeMultikey(oldKey)
+	if err != nil {
+		return err
+	}
+	actor := account.Actor
+	retired := Multikey{
+		ID:                 actor.PublicKeyID(),
+		Type:               "Multikey",
+		Controller:         actor.URI(),
+		PublicKeyMultibase: multibase,
+	}
+	retiredIDs := []string{retired.ID}
+	keys := []Multikey{retired}
+	var ed25519Keypair *crypto.Keypair
+	if len(account.Ed25519PrivateKey) > 0 {
+		// the old Ed25519 key is already published in the assertionMethod.
+		retiredIDs = append(retiredIDs, account.Ed25519KeyID())
+		if ed25519Keypair, err = crypto.GenerateEd25519Keypair(); err != nil {
+			return err
+		}
+		privateKey, err := crypto.ParseEd25519PrivateKey(ed25519Keypair.PrivateKey)
+		if err != nil {
+			return err
+		}
+		multibase, err := crypto.EncodeMultikey(privateKey.Public())
+		if err != nil {
+			return err
+		}
+		keys = append([]Multikey{{
+			ID:                 fmt.Sprintf("%s#ed25519-key-%d", actor.URI(), snowflake.Now()),
+			Type:               "Multikey",
+			Controller:         actor.URI(),
+			PublicKeyMultibase: multibase,
+		}}, keys...)
+	}
+	for _, key := range actor.AssertionMethod() {
+		if key.ID != retired.ID {
+			keys = append(keys, key)
+		}
+	}
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(account).UpdateColumn("private_key", keypair.PrivateKey).Error; err != nil {
+			return err
+		}
+		account.PrivateKey = keypair.PrivateKey
+		if ed25519Keypair != nil {
+			if err := tx.Model(account).UpdateColumn("ed25519_private_key", ed25519Keypair.PrivateKey).Error; err != nil {
+				return err
+			}
+			account.Ed25519PrivateKey = ed25519Keypair.PrivateKey
+		}
+		for _, id := range retiredIDs {
+			if err := tx.Create(&AccountRetiredKey{
+				AccountID: account.ID,
+				KeyID:     id,
+				ExpiresAt: time.Now().Add(grace),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return NewActors(tx).Update(actor, map[string]any{
+			"publicKey": map[string]any{
+				// the new key has a new id, so signatures made with the old key still find it.
+				"id":           fmt.Sprintf("%s#key-%d", actor.URI(), snowflake.Now()),
+				"owner":        actor.URI(),
+				"publicKeyPem": string(keypair.PublicKey),
+			},
+			"assertionMethod": keys,
+		})
+	})
+}
+
+// ExpireRetiredKeys stops publishing the retired keys which expired before now.
+func (a *Accounts) ExpireRetiredKeys(now time.Time) error {
+	var expired []*AccountRetiredKey
+	if err := a.db.Preload("Account").Preload("Account.Actor").Preload("Account.Actor.Object").Where("expires_at < ?", now).Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, key := range expired {
+		if err := a.db.Transaction(func(tx *gorm.DB) error {
+			var keys []Multikey
+			for _, mk := range key.Account.Actor.AssertionMethod() {
+				if mk.ID != key.KeyID {
+					keys = append(keys, mk)
+				}
+			}
+			var assertionMethod any // nil removes the property
+			if len(keys) > 0 {
+				assertionMethod = keys
+			}
+			if err := NewActors(tx).updateProperties(key.Account.Actor, map[string]any{
+				"assertionMethod": assertionMethod,
+			}); err != nil {
+				return err
+			}
+			return tx.Delete(key).Error
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -28,21 +28,42 @@ func MockActor(t *testing.T, tx *gorm.DB, name, domain string, opts ...func(*Act
 	_, err := crypto.GenerateRSAKeypair()
 	require.NoError(err)
 
+	uri := fmt.Sprintf("https://%s/%s", domain, name)
 	obj := &Object{
 		Properties: map[string]any{
 			"published":         time.Now().Format(time.RFC3339),
-			"id":                fmt.Sprintf("https://%s/%s", domain, name),
+			"id":                uri,
 			"type":              "Person",
 			"preferredUsername": name,
 			"displayName":       name,
+			"inbox":             uri + "/inbox",
+			"endpoints": map[string]any{
+				"sharedInbox": fmt.Sprintf("https://%s/inbox", domain),
+			},
 		},
 	}
 	require.NoError(tx.Create(&obj).Error)
 	var actor Actor
 	require.NoError(tx.Scopes(PreloadActor).Where(&Actor{ObjectID: obj.ID}).Take(&actor).Error)
+	for _, opt := range opts {
+		opt(&actor)
+	}
+	if len(opts) > 0 {
+		require.NoError(tx.Session(&gorm.Session{SkipHooks: true}).Save(&actor).Error)
+	}
 	return &actor
 }
 
+// LocalActor is an option for MockActor which marks the actor as local.
+func LocalActor(a *Actor) {
+	a.Type = "LocalPerson"
+}
+
+// RemoteActor is an option for MockActor which marks the actor as remote.
+func RemoteActor(a *Actor) {
+	a.Type = "Person"
+}
+
 func MockStatus(t *testing.T, tx *gorm.DB, actor *Actor, note string) *Status {
 	t.Helper()
 	require := require.New(t)
@@ -12,6 +12,7 @@ import (
 	"github.com/davecheney/pub/internal/activitypub"
 	"github.com/davecheney/pub/internal/snowflake"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Object represents an ActivityPub object.
@@ -20,6 +21,9 @@ type Object struct {
 	Type       string         `gorm:"type:varchar(16);not null"`
 	URI        string         `gorm:"type:varchar(255);not null;uniqueIndex"`
 	Properties map[string]any `gorm:"serializer:json;not null"`
+
+	// previous is the stored version of a Note or Question being saved, if any.
+	previous *Object
 }
 
 func (o *Object) BeforeSave(tx *gorm.DB) error {
@@ -39,6 +43,19 @@ func (o *Object) BeforeSave(tx *gorm.DB) error {
 		o.Type = typ
 	}
 
+	switch o.Type {
+	case "Note", "Question":
+		// keep the stored version so edits can be recorded once the save completes.
+		o.previous = nil
+		var previous []*Object
+		if err := tx.Where("uri = ?", o.URI).Limit(1).Find(&previous).Error; err != nil {
+			return err
+		}
+		if len(previous) > 0 {
+			o.previous = previous[0]
+		}
+	}
+
 	if o.ID == 0 {
 		switch published := o.Properties["published"].(type) {
 		case string:
@@ -72,7 +89,7 @@ func (o *Object) AfterSave(tx *gorm.DB) error {
 	case "Person", "Service":
 		return o.maybeSaveActor(tx)
 	case "Note", "Question":
-		return o.maybeCreateStatus(tx)
+		return forEach(tx, o.maybeCreateStatus, o.maybeSavePoll, o.maybeSaveRevision)
 	case "Announce":
 		return o.maybeCreateReblog(tx)
 	default:
@@ -144,6 +161,21 @@ func (o *Object) maybeCreateStatus(tx *gorm.DB) error {
 		}
 	}
 
+	if o.previous != nil {
+		// an edit of an existing status; leave its conversation, visibility and
+		// counts alone and record when it was updated.
+		var count int64
+		if err := tx.Model(&Status{}).Where("object_id = ?", o.previous.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			if updatedAt.IsZero() {
+				return nil
+			}
+			return tx.Model(&Status{ObjectID: o.previous.ID}).UpdateColumn("updated_at", updatedAt).Error
+		}
+	}
+
 	status := Status{
 		ObjectID:         o.ID,
 		UpdatedAt:        updatedAt,
@@ -194,7 +226,9 @@ func (o *Object) maybeCreateReblog(tx *gorm.DB) error {
 		Reblog:       original,
 	}
 
-	return tx.Save(status).Error
+	// Create, rather than Save, so the AfterCreate hooks run once the row exists
+	// and the reblogs_count of the original status includes this reblog.
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(status).Error
 }
 
 func inReplyToID(inReplyTo *Status) *snowflake.ID {
@@ -1,5 +1,88 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Peer is a remote domain known to this instance, and the health of
+// deliveries to it.
 type Peer struct {
 	Domain string `gorm:"primary_key;size:64"`
+	// Failures is the number of consecutive failed deliveries to the domain.
+	Failures uint32 `gorm:"not null;default:0"`
+	// LastFailureAt is the time of the most recent failed delivery.
+	LastFailureAt time.Time
+	// PausedUntil is the time until which delivery to the domain is paused.
+	PausedUntil time.Time
+}
+
+const (
+	// peerFailureThreshold is the number of consecutive failures after which
+	// delivery to a domain is paused.
+	peerFailureThreshold = 5
+
+	// peerMaxPause is the longest delivery to a domain will be paused.
+	peerMaxPause = 24 * time.Hour
+)
+
+type Peers struct {
+	db *gorm.DB
+}
+
+func NewPeers(db *gorm.DB) *Peers {
+	return &Peers{db: db}
+}
+
+// PausedUntil returns the time until which delivery to the domain is paused,
+// and true if that time is in the future.
+func (p *Peers) PausedUntil(domain string) (time.Time, bool, error) {
+	var peers []Peer
+	if err := p.db.Where("domain = ?", domain).Limit(1).Find(&peers).Error; err != nil {
+		return time.Time{}, false, err
+	}
+	if len(peers) == 0 {
+		return time.Time{}, false, nil
+	}
+	return peers[0].PausedUntil, peers[0].PausedUntil.After(time.Now()), nil
+}
+
+// Succeeded records a successful delivery to the domain, resuming delivery if
+// it was paused.
+func (p *Peers) Succeeded(domain string) error {
+	return p.db.Model(&Peer{}).Where("domain = ? AND failures > 0", domain).Updates(map[string]any{
+		"failures":     0,
+		"paused_until": time.Time{},
+	}).Error
+}
+
+// Failed records a failed delivery to the domain. Once the domain has failed
+// peerFailureThreshold times in a row, delivery is paused for a period which
+// doubles with each further failure, up to peerMaxPause. Delivery resumes
+// automatically once the pause expires.
+func (p *Peers) Failed(domain string) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		peer := Peer{Domain: domain}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "domain"}},
+			DoNothing: true,
+		}).Create(&peer).Error; err != nil {
+			return err
+		}
+		if err := tx.Take(&peer, "domain = ?", domain).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		updates := map[string]any{
+			"failures":        peer.Failures + 1,
+			"last_failure_at": now,
+		}
+		if n := peer.Failures + 1; n >= peerFailureThreshold {
+			pause := time.Minute << min(n-peerFailureThreshold, 11)
+			updates["paused_until"] = now.Add(min(pause, peerMaxPause))
+		}
+		return tx.Model(&peer).Updates(updates).Error
+	})
 }
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeers(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Delivery to a failing domain is paused and resumed", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		peers := NewPeers(tx)
+		for i := 0; i < peerFailureThreshold-1; i++ {
+			require.NoError(peers.Failed("example.org"))
+		}
+		_, paused, err := peers.PausedUntil("example.org")
+		require.NoError(err)
+		require.False(paused)
+
+		require.NoError(peers.Failed("example.org"))
+		until, paused, err := peers.PausedUntil("example.org")
+		require.NoError(err)
+		require.True(paused)
+		require.WithinDuration(time.Now().Add(time.Minute), until, 5*time.Second)
+
+		// each further failure doubles the pause
+		require.NoError(peers.Failed("example.org"))
+		until, _, err = peers.PausedUntil("example.org")
+		require.NoError(err)
+		require.WithinDuration(time.Now().Add(2*time.Minute), until, 5*time.Second)
+
+		require.NoError(peers.Succeeded("example.org"))
+		_, paused, err = peers.PausedUntil("example.org")
+		require.NoError(err)
+		require.False(paused)
+
+		var peer Peer
+		require.NoError(tx.Take(&peer, "domain = ?", "example.org").Error)
+		require.EqualValues(0, peer.Failures)
+	})
+
+	t.Run("Unknown domains are not paused", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		_, paused, err := NewPeers(tx).PausedUntil("example.net")
+		require.NoError(err)
+		require.False(paused)
+	})
+}
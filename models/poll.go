@@ -0,0 +1,290 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/davecheney/pub/internal/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPollExpired is returned when voting on a poll which has closed.
+	ErrPollExpired = errors.New("poll has expired")
+
+	// ErrAlreadyVoted is returned when an actor votes again on a single choice poll.
+	ErrAlreadyVoted = errors.New("already voted")
+
+	// ErrInvalidChoice is returned when a vote is not for one of the poll's options,
+	// or a single choice poll is given more than one choice.
+	ErrInvalidChoice = errors.New("invalid choice")
+)
+
+// orderPollOptions orders a poll's options as they were declared.
+func orderPollOptions(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// maybeSavePoll creates or updates the poll of a Question. The options and tallies
+// of remote polls are taken from the Question, the tallies of local polls are
+// maintained as votes arrive.
+func (o *Object) maybeSavePoll(tx *gorm.DB) error {
+	if o.Type != "Question" {
+		return nil
+	}
+	poll, err := objToStatusPoll(o.ID, o.Properties)
+	if err != nil {
+		return err
+	}
+	var existing []StatusPoll
+	if err := tx.Preload("Options", orderPollOptions).Where("status_id = ?", o.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return tx.Create(poll).Error
+	}
+	author, err := NewActors(tx).FindByURI(stringFromAny(o.Properties["attributedTo"]))
+	if err != nil {
+		return err
+	}
+	if author.IsLocal() {
+		return nil
+	}
+	if err := tx.Model(&existing[0]).UpdateColumns(map[string]any{
+		"expires_at":   poll.ExpiresAt,
+		"multiple":     poll.Multiple,
+		"voters_count": poll.VotersCount,
+	}).Error; err != nil {
+		return err
+	}
+	for _, option := range poll.Options {
+		i := slices.IndexFunc(existing[0].Options, func(o StatusPollOption) bool {
+			return o.Title == option.Title
+		})
+		if i < 0 {
+			option.StatusPollID = o.ID
+			if err := tx.Create(&option).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Model(&existing[0].Options[i]).UpdateColumn("count", option.Count).Error; err != nil {
+			return err
+		}
+	}
+	return existing[0].updateVotesCount(tx)
+}
+
+// objToStatusPoll returns the poll described by the properties of a Question.
+// A Question has either oneOf or anyOf options, the latter allowing multiple choices.
+func objToStatusPoll(id snowflake.ID, props map[string]any) (*StatusPoll, error) {
+	poll := &StatusPoll{
+		StatusID:    id,
+		VotersCount: intFromAny(props["votersCount"]),
+	}
+	options := anyToSlice(props["oneOf"])
+	if anyOf := anyToSlice(props["anyOf"]); len(anyOf) > 0 {
+		options = anyOf
+		poll.Multiple = true
+	}
+	endTime := stringFromAny(props["endTime"])
+	if endTime == "" {
+		endTime = stringFromAny(props["closed"])
+	}
+	if endTime != "" {
+		expiresAt, err := time.Parse(time.RFC3339, endTime)
+		if err != nil {
+			return nil, fmt.Errorf("poll %d has invalid endTime %q: %w", id, endTime, err)
+		}
+		poll.ExpiresAt = expiresAt
+	}
+	for _, o := range options {
+		option, _ := o.(map[string]any)
+		if option["type"] != "Note" {
+			return nil, fmt.Errorf("poll %d has invalid option type: %q", id, option["type"])
+		}
+		replies, _ := option["replies"].(map[string]any)
+		poll.Options = append(poll.Options, StatusPollOption{
+			Title: stringFromAny(option["name"]),
+			Count: intFromAny(replies["totalItems"]),
+		})
+	}
+	return poll, nil
+}
+
+func intFromAny(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+type Polls struct {
+	db *gorm.DB
+}
+
+func NewPolls(db *gorm.DB) *Polls {
+	return &Polls{db: db}
+}
+
+// FindByID returns the poll of the status with the given ID, with the votes of
+// the actor, if any.
+func (p *Polls) FindByID(id snowflake.ID, actor *Actor) (*StatusPoll, error) {
+	query := p.db.Preload("Options", orderPollOptions)
+	if actor != nil {
+		query = query.Preload("Votes", "actor_id = ?", actor.ObjectID)
+	}
+	var poll StatusPoll
+	if err := query.Take(&poll, "status_id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// Vote records the actor's votes for the options of the poll at the given indexes.
+// The actor may vote once on a single choice poll, and once for each option of a
+// multiple choice poll. If the actor is local and the poll is remote, their votes
+// are sent to the poll's server. If the poll is local, its Question is updated with
+// the new tallies and sent to the poll's audience.
+func (p *Polls) Vote(poll *StatusPoll, actor *Actor, choices []int) error {
+	if !poll.ExpiresAt.IsZero() && poll.ExpiresAt.Before(time.Now()) {
+		return ErrPollExpired
+	}
+	if len(choices) == 0 || (!poll.Multiple && len(choices) > 1) {
+		return ErrInvalidChoice
+	}
+	for _, choice := range choices {
+		if choice < 0 || choice >= len(poll.Options) {
+			return fmt.Errorf("%w: %d", ErrInvalidChoice, choice)
+		}
+	}
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		var previous int64
+		if err := tx.Model(&StatusPollVote{}).Where("status_poll_id = ? AND actor_id = ?", poll.StatusID, actor.ObjectID).Count(&previous).Error; err != nil {
+			return err
+		}
+		if previous > 0 && !poll.Multiple {
+			return ErrAlreadyVoted
+		}
+		for _, choice := range choices {
+			option := &poll.Options[choice]
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&StatusPollVote{
+				StatusPollID: poll.StatusID,
+				ActorID:      actor.ObjectID,
+				OptionID:     option.ID,
+			})
+			if err := res.Error; err != nil {
+				return err
+			}
+			if res.RowsAffected == 0 {
+				continue // already voted for this option
+			}
+			if err := tx.Model(option).UpdateColumn("count", gorm.Expr("count + 1")).Error; err != nil {
+				return err
+			}
+		}
+		if previous == 0 {
+			if err := tx.Model(poll).UpdateColumn("voters_count", gorm.Expr("voters_count + 1")).Error; err != nil {
+				return err
+			}
+		}
+		if err := poll.updateVotesCount(tx); err != nil {
+			return err
+		}
+		var status Status
+		if err := tx.Preload("Actor.Object").Take(&status, "object_id = ?", poll.StatusID).Error; err != nil {
+			return err
+		}
+		if status.Actor.IsLocal() {
+			return updateQuestion(tx, status.Actor, poll.StatusID)
+		}
+		if actor.IsRemote() {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "status_id"}, {Name: "actor_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"created_at", "updated_at", "attempts"}),
+		}).Create(&StatusPollVoteRequest{
+			StatusID: poll.StatusID,
+			ActorID:  actor.ObjectID,
+		}).Error
+	})
+}
+
+// updateQuestion copies the tallies of the local poll to its Question and queues an
+// update for each remote inbox the Question was addressed to, and each remote voter,
+// which does not already have one pending.
+func updateQuestion(tx *gorm.DB, author *Actor, id snowflake.ID) error {
+	var poll StatusPoll
+	if err := tx.Preload("Options", orderPollOptions).Take(&poll, "status_id = ?", id).Error; err != nil {
+		return err
+	}
+	var obj Object
+	if err := tx.Take(&obj, id).Error; err != nil {
+		return err
+	}
+	key := "oneOf"
+	if poll.Multiple {
+		key = "anyOf"
+	}
+	for _, o := range anyToSlice(obj.Properties[key]) {
+		option, _ := o.(map[string]any)
+		i := slices.IndexFunc(poll.Options, func(o StatusPollOption) bool {
+			return o.Title == stringFromAny(option["name"])
+		})
+		if i < 0 {
+			continue
+		}
+		option["replies"] = map[string]any{
+			"type":       "Collection",
+			"totalItems": poll.Options[i].Count,
+		}
+	}
+	obj.Properties["votersCount"] = poll.VotersCount
+	if err := tx.Save(&obj).Error; err != nil {
+		return err
+	}
+
+	var voters []*Actor
+	if err := tx.Scopes(PreloadActor).Where("object_id IN (?)", tx.Model(&StatusPollVote{}).Select("actor_id").Where("status_poll_id = ?", id)).Find(&voters).Error; err != nil {
+		return err
+	}
+	addressees := append(anyToSlice(obj.Properties["to"]), anyToSlice(obj.Properties["cc"])...)
+	for _, voter := range voters {
+		addressees = append(addressees, voter.URI())
+	}
+	recipients, err := NewActors(tx).Recipients(author, addressees)
+	if err != nil {
+		return err
+	}
+	var pending []snowflake.ID
+	if err := tx.Model(&ActivitypubOutboxRequest{}).Where("object_id = ? AND action = ? AND attempts = 0", id, "update").Pluck("actor_id", &pending).Error; err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if slices.Contains(pending, recipient.ObjectID) {
+			continue
+		}
+		if err := tx.Create(&ActivitypubOutboxRequest{
+			ObjectID: id,
+			ActorID:  recipient.ObjectID,
+			Action:   "update",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Votes returns the actor's votes for the poll.
+func (p *Polls) Votes(poll *StatusPoll, actor *Actor) ([]*StatusPollVote, error) {
+	var votes []*StatusPollVote
+	return votes, p.db.Preload("Option").Where("status_poll_id = ? AND actor_id = ?", poll.StatusID, actor.ObjectID).Find(&votes).Error
+}
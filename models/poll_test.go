@@ -0,0 +1,136 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolls(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Create a local poll and receive votes", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+
+		status, err := NewStatuses(tx).Create(alice, nil, "public", false, "", "", "Tea or coffee?", &StatusPoll{
+			ExpiresAt: time.Now().Add(time.Hour),
+			Options:   []StatusPollOption{{Title: "Tea"}, {Title: "Coffee"}},
+		})
+		require.NoError(err)
+		require.Equal("Question", status.Object.Type)
+		require.NotNil(status.Poll)
+		require.False(status.Poll.Multiple)
+		require.Len(status.Poll.Options, 2)
+		require.Equal("Tea", status.Poll.Options[0].Title)
+
+		polls := NewPolls(tx)
+		require.ErrorIs(polls.Vote(status.Poll, bob, []int{2}), ErrInvalidChoice)
+		require.ErrorIs(polls.Vote(status.Poll, bob, []int{0, 1}), ErrInvalidChoice)
+		require.NoError(polls.Vote(status.Poll, bob, []int{1}))
+		require.ErrorIs(polls.Vote(status.Poll, bob, []int{0}), ErrAlreadyVoted)
+
+		poll, err := polls.FindByID(status.ObjectID, bob)
+		require.NoError(err)
+		require.Equal(1, poll.VotesCount)
+		require.Equal(1, poll.VotersCount)
+		require.Equal(1, poll.Options[1].Count)
+		require.Len(poll.Votes, 1)
+
+		// votes on local polls are not sent anywhere.
+		var count int64
+		require.NoError(tx.Model(&StatusPollVoteRequest{}).Count(&count).Error)
+		require.EqualValues(0, count)
+
+		// the Question carries the new tallies, and is sent to the voter.
+		var obj Object
+		require.NoError(tx.Take(&obj, status.ObjectID).Error)
+		require.EqualValues(1, obj.Properties["votersCount"])
+		options := obj.Properties["oneOf"].([]any)
+		require.EqualValues(0, options[0].(map[string]any)["replies"].(map[string]any)["totalItems"])
+		require.EqualValues(1, options[1].(map[string]any)["replies"].(map[string]any)["totalItems"])
+		var updates []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ? AND action = ?", status.ObjectID, "update").Find(&updates).Error)
+		require.Len(updates, 1)
+		require.Equal(bob.ObjectID, updates[0].ActorID)
+
+		// further votes only queue updates for recipients without one pending.
+		carol := MockActor(t, tx, "carol", "example.net", RemoteActor)
+		require.NoError(polls.Vote(status.Poll, carol, []int{0}))
+		require.NoError(tx.Where("object_id = ? AND action = ?", status.ObjectID, "update").Find(&updates).Error)
+		require.Len(updates, 2)
+	})
+
+	t.Run("Receive a remote poll and vote on it", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+
+		question := func(tea, coffee int) map[string]any {
+			return map[string]any{
+				"id":           "https://example.org/users/bob/statuses/1",
+				"type":         "Question",
+				"attributedTo": bob.URI(),
+				"published":    time.Now().UTC().Format(time.RFC3339),
+				"endTime":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+				"content":      "Tea or coffee?",
+				"to":           []any{"https://www.w3.org/ns/activitystreams#Public"},
+				"votersCount":  float64(tea + coffee),
+				"anyOf": []any{
+					map[string]any{"type": "Note", "name": "Tea", "replies": map[string]any{"type": "Collection", "totalItems": float64(tea)}},
+					map[string]any{"type": "Note", "name": "Coffee", "replies": map[string]any{"type": "Collection", "totalItems": float64(coffee)}},
+				},
+			}
+		}
+		obj := &Object{Properties: question(2, 3)}
+		require.NoError(tx.Create(obj).Error)
+
+		status, err := NewStatuses(tx).FindByURI("https://example.org/users/bob/statuses/1")
+		require.NoError(err)
+		require.NotNil(status.Poll)
+		require.True(status.Poll.Multiple)
+		require.Equal(5, status.Poll.VotesCount)
+
+		polls := NewPolls(tx)
+		require.NoError(polls.Vote(status.Poll, alice, []int{0, 1}))
+
+		var request StatusPollVoteRequest
+		require.NoError(tx.Where("status_id = ? AND actor_id = ?", status.ObjectID, alice.ObjectID).First(&request).Error)
+		votes, err := polls.Votes(status.Poll, alice)
+		require.NoError(err)
+		require.Len(votes, 2)
+
+		// the tallies of remote polls are taken from updates to the Question.
+		obj.Properties = question(4, 4)
+		require.NoError(tx.Save(obj).Error)
+		poll, err := polls.FindByID(status.ObjectID, alice)
+		require.NoError(err)
+		require.Equal(8, poll.VotesCount)
+		require.Equal(8, poll.VotersCount)
+		require.Equal(4, poll.Options[0].Count)
+		require.Len(poll.Votes, 2)
+	})
+
+	t.Run("Votes on an expired poll are rejected", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		status, err := NewStatuses(tx).Create(alice, nil, "public", false, "", "", "Too late", &StatusPoll{
+			ExpiresAt: time.Now().Add(-time.Minute),
+			Options:   []StatusPollOption{{Title: "Yes"}, {Title: "No"}},
+		})
+		require.NoError(err)
+		require.ErrorIs(NewPolls(tx).Vote(status.Poll, bob, []int{0}), ErrPollExpired)
+	})
+}
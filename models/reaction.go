@@ -45,41 +45,98 @@ func (r *Reaction) updateStatusCount(tx *gorm.DB) error {
 // createReactionRequest creates a reaction request between the actor and target if needed.
 func (r *Reaction) createReactionRequest(tx *gorm.DB) error {
 	var original Reaction
-	if err := tx.First(&original, "actor_id = ? and status_id = ?", r.ActorID, r.StatusID).Error; err != nil {
+	if err := tx.Preload("Actor").Preload("Actor.Object").First(&original, "actor_id = ? and status_id = ?", r.ActorID, r.StatusID).Error; err != nil {
 		return err
 	}
+	if original.Actor.IsRemote() {
+		// don't create a reaction request for reactions received from remote actors
+		return nil
+	}
 	fmt.Printf("reaction changed from %+v to %+v\n", original, r)
 
-	// if there is a conflict; eg. a like then an unlike before the follow is processed
-	// update the existing row to reflect the new action.
-	tx = tx.Clauses(clause.OnConflict{
-		Columns: []clause.Column{{Name: "actor_id"}, {Name: "target_id"}},
+	// what changed? each kind of reaction has its own requests, so a pending
+	// like is not replaced by a reblog or pin of the same status.
+	changes := []struct {
+		before, after bool
+		do, undo      ReactionRequestAction
+	}{
+		{original.Favourited, r.Favourited, "like", "unlike"},
+		{original.Reblogged, r.Reblogged, "reblog", "unreblog"},
+		{original.Pinned, r.Pinned, "pin", "unpin"},
+	}
+	for _, change := range changes {
+		var action ReactionRequestAction
+		switch {
+		case change.before && !change.after:
+			action = change.undo
+		case !change.before && change.after:
+			action = change.do
+		default:
+			continue
+		}
+		status, err := NewStatuses(tx).FindByID(r.StatusID)
+		if err != nil {
+			return err
+		}
+		if err := queueReactionRequests(tx, original.Actor, status, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueReactionRequests queues a request to perform the action for each of its recipients.
+// If there is a conflict; eg. a like then an unlike before the like is processed, the
+// existing request of that kind to the recipient is updated to reflect the new action.
+// Pending requests of that kind to actors which are no longer recipients are removed.
+func queueReactionRequests(tx *gorm.DB, actor *Actor, status *Status, action ReactionRequestAction) error {
+	kind := action.Kind()
+	recipients, err := reactionRecipients(tx, actor, status, kind)
+	if err != nil {
+		return err
+	}
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "actor_id"}, {Name: "target_id"}, {Name: "kind"}, {Name: "recipient_id"}},
 		DoUpdates: clause.AssignmentColumns([]string{
 			"action",
 			"created_at",
 			"updated_at",
 			"attempts", // resets the attempts counter
+			"next_attempt_at",
 		}),
-	})
+	}
+	ids := []snowflake.ID{0} // never an actor, so NOT IN is never empty
+	for _, recipient := range recipients {
+		if err := tx.Clauses(onConflict).Create(&ReactionRequest{
+			ActorID:     actor.ObjectID,
+			TargetID:    status.ObjectID,
+			RecipientID: recipient.ObjectID,
+			Kind:        kind,
+			Action:      action,
+		}).Error; err != nil {
+			return err
+		}
+		ids = append(ids, recipient.ObjectID)
+	}
+	return tx.Where("actor_id = ? and target_id = ? and kind = ? and recipient_id not in ?", actor.ObjectID, status.ObjectID, kind, ids).Delete(&ReactionRequest{}).Error
+}
 
-	// what changed?
-	switch {
-	case original.Favourited && !r.Favourited:
-		// undo like
-		return tx.Create(&ReactionRequest{
-			ActorID:  r.ActorID,
-			TargetID: r.StatusID,
-			Action:   "unlike",
-		}).Error
-	case !original.Favourited && r.Favourited:
-		// like
-		return tx.Create(&ReactionRequest{
-			ActorID:  r.ActorID,
-			TargetID: r.StatusID,
-			Action:   "like",
-		}).Error
+// reactionRecipients returns the actors to whom a change of the kind of reaction is delivered.
+func reactionRecipients(tx *gorm.DB, actor *Actor, status *Status, kind string) ([]*Actor, error) {
+	switch kind {
+	case "reblog":
+		// the original author, and each of the reblogger's followers.
+		return NewActors(tx).Recipients(actor, []any{
+			status.Actor.URI(),
+			actor.URI() + "/followers",
+		})
+	case "pin":
+		// each of the actor's followers.
+		return NewActors(tx).Recipients(actor, []any{
+			actor.URI() + "/followers",
+		})
 	default:
-		return nil
+		return []*Actor{status.Actor}, nil
 	}
 }
 
@@ -90,22 +147,41 @@ type ReactionRequest struct {
 	Request
 
 	// ActorID is the ID of the actor that is requesting the reaction change.
-	ActorID snowflake.ID `gorm:"uniqueIndex:uidx_reaction_requests_actor_id_target_id;not null;"`
+	ActorID snowflake.ID `gorm:"uniqueIndex:uidx_reaction_requests_actor_id_target_id_kind_recipient_id;not null;"`
 	// Actor is the actor that is requesting the reaction change.
 	Actor    *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false"`
-	TargetID snowflake.ID `gorm:"uniqueIndex:uidx_reaction_requests_actor_id_target_id;not null;"`
+	TargetID snowflake.ID `gorm:"uniqueIndex:uidx_reaction_requests_actor_id_target_id_kind_recipient_id;not null;"`
 	// Target is the status that is being reacted to.
 	Target *Status `gorm:"constraint:OnDelete:CASCADE;<-:false"`
-	// Action is the action to perform, either follow or unfollow.
+	// RecipientID is the ID of the actor the reaction change is delivered to.
+	RecipientID snowflake.ID `gorm:"uniqueIndex:uidx_reaction_requests_actor_id_target_id_kind_recipient_id;not null;"`
+	// Recipient is the actor the reaction change is delivered to.
+	Recipient *Actor `gorm:"constraint:OnDelete:CASCADE;<-:false"`
+	// Kind is the kind of reaction being changed; like, reblog, or pin.
+	// Only the undo of a pending action replaces it, eg. an unlike replaces a like.
+	Kind string `gorm:"size:8;uniqueIndex:uidx_reaction_requests_actor_id_target_id_kind_recipient_id;not null;default:''"`
+	// Action is the action to perform; like, unlike, reblog, unreblog, pin, or unpin.
 	Action ReactionRequestAction `gorm:"not null"`
 }
 
 type ReactionRequestAction string
 
+// Kind returns the kind of reaction the action changes; like, reblog, or pin.
+func (a ReactionRequestAction) Kind() string {
+	switch a {
+	case "like", "unlike":
+		return "like"
+	case "reblog", "unreblog":
+		return "reblog"
+	default:
+		return "pin"
+	}
+}
+
 func (ReactionRequestAction) GormDBDataType(db *gorm.DB, field *schema.Field) string {
 	switch db.Dialector.Name() {
 	case "mysql", "postgres":
-		return "enum('like', 'unlike')"
+		return "enum('like', 'unlike', 'reblog', 'unreblog', 'pin', 'unpin')"
 	case "sqlite":
 		return "TEXT"
 	default:
@@ -121,6 +197,12 @@ func NewReactions(db *gorm.DB) *Reactions {
 	return &Reactions{db: db}
 }
 
+// Requeue queues the action of the actor on the status for each of its current recipients,
+// replacing any pending requests of the same kind.
+func (r *Reactions) Requeue(actor *Actor, status *Status, action ReactionRequestAction) error {
+	return queueReactionRequests(r.db, actor, status, action)
+}
+
 func (r *Reactions) Pin(status *Status, actor *Actor) (*Reaction, error) {
 	reaction, err := findOrCreateReaction(r.db, status, actor)
 	if err != nil {
@@ -192,7 +274,7 @@ func (r *Reactions) Reblog(status *Status, actor *Actor) (*Status, error) {
 		}
 		props := map[string]any{
 			"type":      "Announce",
-			"id":        fmt.Sprintf("https://%s/u/%s/status/%d/activity", actor.Domain, actor.Name, id),
+			"id":        ReblogURI(actor, status),
 			"actor":     actor.URI(),
 			"object":    status.URI(),
 			"published": id.ToTime().Format(time.RFC3339),
@@ -210,6 +292,7 @@ func (r *Reactions) Reblog(status *Status, actor *Actor) (*Status, error) {
 }
 
 // Unreblog removes the reblog of the given status with the given actor.
+// The Announce object is deleted along with the reblog.
 func (r *Reactions) Unreblog(status *Status, actor *Actor) (*Status, error) {
 	var reblog Status
 	return &reblog, r.db.Transaction(func(tx *gorm.DB) error {
@@ -226,10 +309,17 @@ func (r *Reactions) Unreblog(status *Status, actor *Actor) (*Status, error) {
 		if err := tx.Where("reblog_id = ? AND actor_id = ?", status.ObjectID, actor.ObjectID).Preload("Actor").First(&reblog).Error; err != nil {
 			return err
 		}
-		return tx.Delete(&reblog).Error
+		return tx.Delete(&Object{ID: reblog.ObjectID}).Error
 	})
 }
 
+// ReblogURI returns the URI of the Announce activity for the actor's reblog of the status.
+// The URI is derived from the original status so that an Undo can be addressed to it
+// after the reblog has been deleted.
+func ReblogURI(actor *Actor, status *Status) string {
+	return fmt.Sprintf("https://%s/u/%s/reblogs/%d", actor.Domain, actor.Name, status.ObjectID)
+}
+
 func findOrCreateReaction(tx *gorm.DB, status *Status, actor *Actor) (*Reaction, error) {
 	status.Reaction = &Reaction{
 		StatusID: status.ObjectID,
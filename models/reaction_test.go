@@ -3,7 +3,9 @@ package models
 import (
 	"testing"
 
+	"github.com/davecheney/pub/internal/snowflake"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestReactions(t *testing.T) {
@@ -15,7 +17,7 @@ func TestReactions(t *testing.T) {
 		defer tx.Rollback()
 
 		author := MockActor(t, tx, "alice", "example.com")
-		favouritedBy := MockActor(t, tx, "bob", "example.com")
+		favouritedBy := MockActor(t, tx, "bob", "example.com", LocalActor)
 		status := MockStatus(t, tx, author, "This speech is my recital, I think it's very vital")
 
 		reactions := NewReactions(tx)
@@ -53,6 +55,41 @@ func TestReactions(t *testing.T) {
 		require.EqualValues(0, st.FavouritesCount)
 	})
 
+	t.Run("Each kind of reaction has its own request", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		_, err := NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+		status := MockStatus(t, tx, alice, "Cry me a river")
+
+		reactions := NewReactions(tx)
+		_, err = reactions.Favourite(status, alice)
+		require.NoError(err)
+		_, err = reactions.Pin(status, alice)
+		require.NoError(err)
+
+		actions := func() map[string]ReactionRequestAction {
+			var requests []ReactionRequest
+			require.NoError(tx.Where("actor_id = ? AND target_id = ?", alice.ObjectID, status.ObjectID).Find(&requests).Error)
+			actions := make(map[string]ReactionRequestAction)
+			for _, request := range requests {
+				actions[request.Kind] = request.Action
+			}
+			return actions
+		}
+		// the pin does not replace the pending like.
+		require.Equal(map[string]ReactionRequestAction{"like": "like", "pin": "pin"}, actions())
+
+		// but the unlike does.
+		_, err = reactions.Unfavourite(status, alice)
+		require.NoError(err)
+		require.Equal(map[string]ReactionRequestAction{"like": "unlike", "pin": "pin"}, actions())
+	})
+
 	t.Run("Pin and Unpin", func(t *testing.T) {
 		require := require.New(t)
 		tx := db.Begin()
@@ -79,24 +116,99 @@ func TestReactions(t *testing.T) {
 		require.False(reaction.Pinned)
 	})
 
+	t.Run("Pin by a local actor", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		carol := MockActor(t, tx, "carol", "example.net", RemoteActor)
+		relationships := NewRelationships(tx)
+		_, err := relationships.Follow(bob, alice)
+		require.NoError(err)
+		_, err = relationships.Follow(carol, alice)
+		require.NoError(err)
+		status := MockStatus(t, tx, alice, "Pin me up")
+
+		reactions := NewReactions(tx)
+		_, err = reactions.Pin(status, alice)
+		require.NoError(err)
+
+		// the pin is delivered to each of alice's followers.
+		var requests []ReactionRequest
+		require.NoError(tx.Where("actor_id = ? AND target_id = ?", alice.ObjectID, status.ObjectID).Find(&requests).Error)
+		require.Len(requests, 2)
+		recipients := []snowflake.ID{requests[0].RecipientID, requests[1].RecipientID}
+		require.ElementsMatch([]snowflake.ID{bob.ObjectID, carol.ObjectID}, recipients)
+
+		var request ReactionRequest
+		err = tx.Where("actor_id = ? AND target_id = ? AND recipient_id = ?", alice.ObjectID, status.ObjectID, bob.ObjectID).First(&request).Error
+		require.NoError(err)
+		require.EqualValues("pin", request.Action)
+
+		_, err = reactions.Unpin(status, alice)
+		require.NoError(err)
+
+		err = tx.Where("actor_id = ? AND target_id = ?", alice.ObjectID, status.ObjectID).First(&request).Error
+		require.NoError(err)
+		require.EqualValues("unpin", request.Action)
+	})
+
+	t.Run("Favourite from a remote actor", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		author := MockActor(t, tx, "alice", "example.com", LocalActor)
+		favouritedBy := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		status := MockStatus(t, tx, author, "Papa was a rolling stone")
+
+		reactions := NewReactions(tx)
+		_, err := reactions.Favourite(status, favouritedBy)
+		require.NoError(err)
+
+		st, err := NewStatuses(tx).FindByID(status.ObjectID)
+		require.NoError(err)
+		require.EqualValues(1, st.FavouritesCount)
+
+		_, err = reactions.Unfavourite(status, favouritedBy)
+		require.NoError(err)
+
+		st, err = NewStatuses(tx).FindByID(status.ObjectID)
+		require.NoError(err)
+		require.EqualValues(0, st.FavouritesCount)
+
+		// reactions from remote actors are not sent back out
+		var count int64
+		require.NoError(tx.Model(&ReactionRequest{}).Where("actor_id = ?", favouritedBy.ObjectID).Count(&count).Error)
+		require.EqualValues(0, count)
+	})
+
 	t.Run("Reblog and Unreblog", func(t *testing.T) {
 		require := require.New(t)
 		tx := db.Begin()
 		defer tx.Rollback()
 
 		author := MockActor(t, tx, "alice", "example.com")
-		rebloggedBy := MockActor(t, tx, "bob", "example.com")
+		rebloggedBy := MockActor(t, tx, "bob", "example.com", LocalActor)
 		status := MockStatus(t, tx, author, "This speech is my recital, I think it's very vital")
 
 		reactions := NewReactions(tx)
-		_, err := reactions.Reblog(status, rebloggedBy)
+		reblog, err := reactions.Reblog(status, rebloggedBy)
 		require.NoError(err)
+		require.Equal(ReblogURI(rebloggedBy, status), reblog.URI())
 
 		var reaction Reaction
 		err = tx.Where("status_id = ? AND actor_id = ?", status.ObjectID, rebloggedBy.ObjectID).First(&reaction).Error
 		require.NoError(err)
 		require.True(reaction.Reblogged)
 
+		var request ReactionRequest
+		err = tx.Where("actor_id = ? AND target_id = ?", rebloggedBy.ObjectID, status.ObjectID).First(&request).Error
+		require.NoError(err)
+		require.EqualValues("reblog", request.Action)
+
 		st, err := NewStatuses(tx).FindByID(status.ObjectID)
 		require.NoError(err)
 		require.EqualValues(1, st.ReblogsCount)
@@ -108,11 +220,50 @@ func TestReactions(t *testing.T) {
 		require.NoError(err)
 		require.False(reaction.Reblogged)
 
+		err = tx.Where("actor_id = ? AND target_id = ?", rebloggedBy.ObjectID, status.ObjectID).First(&request).Error
+		require.NoError(err)
+		require.EqualValues("unreblog", request.Action)
+
+		err = tx.Where("uri = ?", ReblogURI(rebloggedBy, status)).First(&Object{}).Error
+		require.ErrorIs(err, gorm.ErrRecordNotFound)
+
 		st, err = NewStatuses(tx).FindByID(status.ObjectID)
 		require.NoError(err)
 		require.EqualValues(0, st.ReblogsCount)
 	})
 
+	t.Run("Reblog is queued for each recipient", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.org", RemoteActor)
+		bob := MockActor(t, tx, "bob", "example.com", LocalActor)
+		carol := MockActor(t, tx, "carol", "example.net", RemoteActor)
+		_, err := NewRelationships(tx).Follow(carol, bob)
+		require.NoError(err)
+		status := MockStatus(t, tx, alice, "Don't you want me baby")
+
+		actions := func() map[snowflake.ID]ReactionRequestAction {
+			var requests []ReactionRequest
+			require.NoError(tx.Where("actor_id = ? AND target_id = ? AND kind = ?", bob.ObjectID, status.ObjectID, "reblog").Find(&requests).Error)
+			actions := make(map[snowflake.ID]ReactionRequestAction)
+			for _, request := range requests {
+				actions[request.RecipientID] = request.Action
+			}
+			return actions
+		}
+
+		reactions := NewReactions(tx)
+		_, err = reactions.Reblog(status, bob)
+		require.NoError(err)
+		require.Equal(map[snowflake.ID]ReactionRequestAction{alice.ObjectID: "reblog", carol.ObjectID: "reblog"}, actions())
+
+		_, err = reactions.Unreblog(status, bob)
+		require.NoError(err)
+		require.Equal(map[snowflake.ID]ReactionRequestAction{alice.ObjectID: "unreblog", carol.ObjectID: "unreblog"}, actions())
+	})
+
 	t.Run("Bookmark and Unbookmark", func(t *testing.T) {
 		require := require.New(t)
 		tx := db.Begin()
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/davecheney/pub/internal/snowflake"
 	"gorm.io/gorm"
@@ -19,7 +20,23 @@ type Relationship struct {
 	BlockedBy  bool         `gorm:"not null;default:false"`
 	Following  bool         `gorm:"not null;default:false"`
 	FollowedBy bool         `gorm:"not null;default:false"`
-	Note       string       `gorm:"type:text"`
+	// Requested is true if the actor has requested to follow the target
+	// and the target has not yet accepted or rejected the request.
+	Requested bool `gorm:"not null;default:false"`
+	// RequestedAt is the time the actor last requested to follow the target.
+	RequestedAt time.Time `gorm:"index"`
+	// RequestedBy is true if the target has requested to follow the actor
+	// and the actor has not yet accepted or rejected the request.
+	RequestedBy bool   `gorm:"not null;default:false"`
+	Note        string `gorm:"type:text"`
+	// FollowURI is the id of the actor's Follow of the target. If the actor is remote,
+	// it is echoed in the Accept or Reject of the follow. If the actor is local, the
+	// target's Accept or Reject may refer to the follow by it.
+	FollowURI string `gorm:"size:255"`
+
+	// rejected is set when the target has rejected the actor's follow, which
+	// is already removed by the target, so there is nothing to undo.
+	rejected bool
 }
 
 // BeforeUpdate creates a relationship request between the actor and target.
@@ -27,11 +44,11 @@ func (r *Relationship) BeforeUpdate(tx *gorm.DB) error {
 	return forEach(tx, r.updateRelationshipRequest)
 }
 
-// updateRelationshipRequest schedules a ActivityPub follow or unfollow request if
-// the actor has changed their relationship with the target.
+// updateRelationshipRequest schedules a ActivityPub follow, unfollow, block, unblock,
+// accept, or reject request if the actor has changed their relationship with the target.
 func (r *Relationship) updateRelationshipRequest(tx *gorm.DB) error {
 	var original Relationship
-	if err := tx.Preload("Actor").Take(&original, "actor_id = ? and target_id = ?", r.ActorID, r.TargetID).Error; err != nil {
+	if err := tx.Preload("Actor").Preload("Actor.Object").Take(&original, "actor_id = ? and target_id = ?", r.ActorID, r.TargetID).Error; err != nil {
 		return err
 	}
 	if original.Actor.IsRemote() {
@@ -41,37 +58,60 @@ func (r *Relationship) updateRelationshipRequest(tx *gorm.DB) error {
 
 	fmt.Printf("relationship changed from %+v to %+v\n", original, r)
 
-	// if there is a conflict; eg. a follow then an unfollow before the follow is processed
-	// update the existing row to reflect the new action.
-	tx = tx.Clauses(clause.OnConflict{
-		Columns: []clause.Column{{Name: "actor_id"}, {Name: "target_id"}},
+	// what changed?
+	// blocks are checked first as blocking also severs any follow, which
+	// the remote server is expected to do itself on receipt of the block.
+	switch {
+	case original.Blocking && !r.Blocking:
+		// unblock
+		return createRelationshipRequest(tx, r.ActorID, r.TargetID, "unblock")
+	case !original.Blocking && r.Blocking:
+		// block
+		return createRelationshipRequest(tx, r.ActorID, r.TargetID, "block")
+	case !original.BlockedBy && r.BlockedBy:
+		// blocked by the target, which has already removed any follow between them.
+		return nil
+	case (original.Following || original.Requested) && !(r.Following || r.Requested):
+		if r.rejected {
+			return nil
+		}
+		// unfollow, or withdraw a pending follow request
+		return createRelationshipRequest(tx, r.ActorID, r.TargetID, "unfollow")
+	case !(original.Following || original.Requested) && (r.Following || r.Requested):
+		// follow; a request which is later accepted does not need to be sent again
+		r.FollowURI = fmt.Sprintf("%s#follows/%d", original.Actor.URI(), snowflake.Now())
+		return createRelationshipRequest(tx, r.ActorID, r.TargetID, "follow")
+	case !original.FollowedBy && r.FollowedBy:
+		// accept the target's follow
+		return createRelationshipRequest(tx, r.ActorID, r.TargetID, "accept")
+	case original.RequestedBy && !r.RequestedBy && !r.FollowedBy:
+		// reject the target's follow request
+		return createRelationshipRequest(tx, r.ActorID, r.TargetID, "reject")
+	default:
+		return nil
+	}
+}
+
+// createRelationshipRequest queues the action from the actor to the target.
+// Each kind of action has its own request, and only the opposite action of the
+// same kind replaces a pending request; eg. an unfollow replaces a pending follow,
+// but a follow does not replace a pending accept.
+func createRelationshipRequest(tx *gorm.DB, actorID, targetID snowflake.ID, action RelationshipRequestAction) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "actor_id"}, {Name: "target_id"}, {Name: "kind"}},
 		DoUpdates: clause.AssignmentColumns([]string{
 			"action",
 			"created_at",
 			"updated_at",
 			"attempts", // resets the attempts counter
+			"next_attempt_at",
 		}),
-	})
-
-	// what changed?
-	switch {
-	case original.Following && !r.Following:
-		// unfollow
-		return tx.Create(&RelationshipRequest{
-			ActorID:  r.ActorID,
-			TargetID: r.TargetID,
-			Action:   "unfollow",
-		}).Error
-	case !original.Following && r.Following:
-		// follow
-		return tx.Create(&RelationshipRequest{
-			ActorID:  r.ActorID,
-			TargetID: r.TargetID,
-			Action:   "follow",
-		}).Error
-	default:
-		return nil
-	}
+	}).Create(&RelationshipRequest{
+		ActorID:  actorID,
+		TargetID: targetID,
+		Kind:     action.Kind(),
+		Action:   action,
+	}).Error
 }
 
 // AfterUpdate updates the followers and following counts for the actor and target.
@@ -97,29 +137,46 @@ func (r *Relationship) updateFollowingCount(tx *gorm.DB) error {
 	return tx.Model(actor).Update("following_count", following).Error
 }
 
-// A RelationshipRequest records a request to follow or unfollow an actor.
+// A RelationshipRequest records a request to follow, unfollow, block, or unblock an actor,
+// or to accept or reject an actor's follow.
 // RelationshipRequests are created by hooks on the Relationship model, and are
 // processed by the RelationshipRequestProcessor in the background.
 type RelationshipRequest struct {
 	Request
 
 	// ActorID is the ID of the actor that is requesting the relationship change.
-	ActorID snowflake.ID `gorm:"uniqueIndex:uidx_relationship_requests_actor_id_target_id;not null;"`
+	ActorID snowflake.ID `gorm:"uniqueIndex:uidx_relationship_requests_actor_id_target_id_kind;not null;"`
 	// Actor is the actor that is requesting the relationship change.
 	Actor    *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
-	TargetID snowflake.ID `gorm:"uniqueIndex:uidx_relationship_requests_actor_id_target_id;not null;"`
-	// Target is the actor that is being followed or unfollowed.
+	TargetID snowflake.ID `gorm:"uniqueIndex:uidx_relationship_requests_actor_id_target_id_kind;not null;"`
+	// Target is the actor that is being followed, unfollowed, blocked, unblocked,
+	// or whose follow is being accepted or rejected.
 	Target *Actor `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
-	// Action is the action to perform, either follow or unfollow.
+	// Kind is the kind of action; follow, block, or response, see RelationshipRequestAction.Kind.
+	Kind string `gorm:"size:8;uniqueIndex:uidx_relationship_requests_actor_id_target_id_kind;not null;default:''"`
+	// Action is the action to perform; follow, unfollow, block, unblock, accept, or reject.
 	Action RelationshipRequestAction `gorm:"not null"`
 }
 
 type RelationshipRequestAction string
 
+// Kind returns the kind of the action; follow for a follow or unfollow, block for
+// a block or unblock, and response for the accept or reject of a follow.
+func (a RelationshipRequestAction) Kind() string {
+	switch a {
+	case "follow", "unfollow":
+		return "follow"
+	case "block", "unblock":
+		return "block"
+	default:
+		return "response"
+	}
+}
+
 func (RelationshipRequestAction) GormDBDataType(db *gorm.DB, field *schema.Field) string {
 	switch db.Dialector.Name() {
 	case "mysql", "postgres":
-		return "enum('follow', 'unfollow')"
+		return "enum('follow', 'unfollow', 'block', 'unblock', 'accept', 'reject')"
 	case "sqlite":
 		return "TEXT"
 	default:
@@ -166,16 +223,21 @@ func (r *Relationships) Unmute(actor, target *Actor) (*Relationship, error) {
 }
 
 // Block blocks the target from the actor.
+// Blocking severs any follow relationship between actor and target in both directions.
 func (r *Relationships) Block(actor, target *Actor) (*Relationship, error) {
 	forward, inverse, err := r.pair(actor, target)
 	if err != nil {
 		return nil, err
 	}
 	forward.Blocking = true
+	forward.Following = false
+	forward.FollowedBy = false
 	if err := r.db.Save(forward).Error; err != nil {
 		return nil, err
 	}
 	inverse.BlockedBy = true
+	inverse.Following = false
+	inverse.FollowedBy = false
 	if err := r.db.Save(inverse).Error; err != nil {
 		return nil, err
 	}
@@ -200,39 +262,167 @@ func (r *Relationships) Unblock(actor, target *Actor) (*Relationship, error) {
 }
 
 // Follow establishes a follow relationship between actor and the target.
+// When a local actor starts following a remote target, a backfill of the
+// target's outbox is scheduled.
 func (r *Relationships) Follow(actor, target *Actor) (*Relationship, error) {
 	forward, inverse, err := r.pair(actor, target)
 	if err != nil {
 		return nil, err
 	}
+	following := forward.Following
 	forward.Following = true
+	forward.Requested = false
 	if err := r.db.Save(forward).Error; err != nil {
 		return nil, err
 	}
 	inverse.FollowedBy = true
+	inverse.RequestedBy = false
 	if err := r.db.Save(inverse).Error; err != nil {
 		return nil, err
 	}
+	if !following && actor.IsLocal() && target.IsRemote() {
+		// fill the follower's home timeline with the target's recent posts.
+		if err := NewActors(r.db).Backfill(target); err != nil {
+			return nil, err
+		}
+	}
 	return forward, nil
 }
 
-// Unfollow removes a follow relationship between actor and the target.
+// FollowOrRequest follows the target if it is a local actor which does not require
+// approval of its followers, otherwise it requests to follow the target.
+func (r *Relationships) FollowOrRequest(actor, target *Actor) (*Relationship, error) {
+	if target.IsRemote() || target.Locked() {
+		// the follow is pending until the target accepts it.
+		return r.RequestFollow(actor, target)
+	}
+	return r.Follow(actor, target)
+}
+
+// RequestFollow records a pending request from actor to follow the target.
+// The request is resolved by the target with AuthorizeFollow or RejectFollow.
+func (r *Relationships) RequestFollow(actor, target *Actor) (*Relationship, error) {
+	forward, inverse, err := r.pair(actor, target)
+	if err != nil {
+		return nil, err
+	}
+	if forward.Following {
+		// already following, nothing to approve
+		return forward, nil
+	}
+	forward.Requested = true
+	forward.RequestedAt = time.Now()
+	if err := r.db.Save(forward).Error; err != nil {
+		return nil, err
+	}
+	inverse.RequestedBy = true
+	if err := r.db.Save(inverse).Error; err != nil {
+		return nil, err
+	}
+	return forward, nil
+}
+
+// ReceiveFollow records the remote actor's Follow of the local target, whose id is followURI.
+// If the target approves its followers, the follow is pending until it is authorized,
+// otherwise it is accepted. If the actor already follows the target, the Accept is sent
+// again, as the actor's server may not have received it. If the target has blocked the
+// actor, the follow is rejected.
+func (r *Relationships) ReceiveFollow(actor, target *Actor, followURI string) (*Relationship, error) {
+	forward, err := r.findOrCreate(actor, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(forward).UpdateColumn("follow_uri", followURI).Error; err != nil {
+		return nil, err
+	}
+	switch {
+	case forward.BlockedBy:
+		return forward, createRelationshipRequest(r.db, target.ObjectID, actor.ObjectID, "reject")
+	case forward.Following:
+		return forward, createRelationshipRequest(r.db, target.ObjectID, actor.ObjectID, "accept")
+	case target.Locked():
+		// the target must approve the follow, see AuthorizeFollow.
+		return r.RequestFollow(actor, target)
+	default:
+		// accepted by the relationship hooks.
+		return r.Follow(actor, target)
+	}
+}
+
+// AuthorizeFollow accepts the follower's pending request to follow the actor.
+// If the follower has not requested to follow the actor, AuthorizeFollow does nothing.
+// The returned Relationship is from the actor to the follower.
+func (r *Relationships) AuthorizeFollow(actor, follower *Actor) (*Relationship, error) {
+	request, err := r.findOrCreate(follower, actor)
+	if err != nil {
+		return nil, err
+	}
+	if request.Requested {
+		if _, err := r.Follow(follower, actor); err != nil {
+			return nil, err
+		}
+	}
+	return r.findOrCreate(actor, follower)
+}
+
+// RejectFollow rejects the follower's pending request to follow the actor.
+// If the follow has already been accepted, it is removed.
+// The returned Relationship is from the actor to the follower.
+func (r *Relationships) RejectFollow(actor, follower *Actor) (*Relationship, error) {
+	forward, inverse, err := r.pair(actor, follower)
+	if err != nil {
+		return nil, err
+	}
+	forward.RequestedBy = false
+	forward.FollowedBy = false
+	if err := r.db.Save(forward).Error; err != nil {
+		return nil, err
+	}
+	inverse.Requested = false
+	inverse.Following = false
+	inverse.rejected = true
+	if err := r.db.Save(inverse).Error; err != nil {
+		return nil, err
+	}
+	return forward, nil
+}
+
+// Unfollow removes a follow relationship, or a pending follow request, between actor and the target.
 func (r *Relationships) Unfollow(actor, target *Actor) (*Relationship, error) {
 	forward, inverse, err := r.pair(actor, target)
 	if err != nil {
 		return nil, err
 	}
 	forward.Following = false
+	forward.Requested = false
 	if err := r.db.Save(forward).Error; err != nil {
 		return nil, err
 	}
 	inverse.FollowedBy = false
+	inverse.RequestedBy = false
 	if err := r.db.Save(inverse).Error; err != nil {
 		return nil, err
 	}
 	return forward, nil
 }
 
+// WithdrawFollowRequests withdraws the pending follow requests of local actors
+// which were made before the given time and have not been accepted or rejected.
+func (r *Relationships) WithdrawFollowRequests(before time.Time) error {
+	var requests []*Relationship
+	query := r.db.Preload("Actor").Preload("Target").
+		Joins("JOIN actors ON actors.object_id = relationships.actor_id AND actors.type IN ?", []string{"LocalPerson", "LocalService"})
+	if err := query.Where("relationships.requested = true AND relationships.requested_at < ?", before).Find(&requests).Error; err != nil {
+		return err
+	}
+	for _, request := range requests {
+		if _, err := r.Unfollow(request.Actor, request.Target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // pair returns the pair of Relationships between actor and target.
 func (r *Relationships) pair(actor, target *Actor) (*Relationship, *Relationship, error) {
 	forward, err := r.findOrCreate(actor, target)
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -93,4 +95,289 @@ func TestRelationships(t *testing.T) {
 		err = tx.Where("actor_id = ? AND target_id = ?", bob.ObjectID, alice.ObjectID).First(&follower).Error
 		require.Error(err)
 	})
+
+	t.Run("Block severs follows", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com")
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		_, err := NewRelationships(tx).Follow(alice, bob)
+		require.NoError(err)
+		_, err = NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+
+		_, err = NewRelationships(tx).Block(bob, alice)
+		require.NoError(err)
+
+		forward, err := NewRelationships(tx).findOrCreate(bob, alice)
+		require.NoError(err)
+		require.True(forward.Blocking)
+		require.False(forward.Following)
+		require.False(forward.FollowedBy)
+
+		inverse, err := NewRelationships(tx).findOrCreate(alice, bob)
+		require.NoError(err)
+		require.True(inverse.BlockedBy)
+		require.False(inverse.Following)
+		require.False(inverse.FollowedBy)
+
+		recipients, err := NewActors(tx).Recipients(alice, []any{bob.URI(), alice.URI() + "/followers"})
+		require.NoError(err)
+		require.Empty(recipients)
+
+		_, err = NewRelationships(tx).Unblock(bob, alice)
+		require.NoError(err)
+
+		inverse, err = NewRelationships(tx).findOrCreate(alice, bob)
+		require.NoError(err)
+		require.False(inverse.BlockedBy)
+
+		recipients, err = NewActors(tx).Recipients(alice, []any{bob.URI()})
+		require.NoError(err)
+		require.Len(recipients, 1)
+	})
+
+	t.Run("Blocked by a remote actor", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		relationships := NewRelationships(tx)
+		_, err := relationships.Follow(alice, bob)
+		require.NoError(err)
+		require.NoError(tx.Where("actor_id = ?", alice.ObjectID).Delete(&RelationshipRequest{}).Error)
+
+		_, err = relationships.Block(bob, alice)
+		require.NoError(err)
+
+		inverse, err := relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.True(inverse.BlockedBy)
+		require.False(inverse.Following)
+
+		// bob has already removed alice's follow, so there is nothing to undo.
+		var count int64
+		require.NoError(tx.Model(&RelationshipRequest{}).Where("actor_id = ?", alice.ObjectID).Count(&count).Error)
+		require.Zero(count)
+	})
+
+	t.Run("Follow requests", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		carol := MockActor(t, tx, "carol", "example.org", RemoteActor)
+		relationships := NewRelationships(tx)
+
+		_, err := relationships.RequestFollow(bob, alice)
+		require.NoError(err)
+		_, err = relationships.RequestFollow(carol, alice)
+		require.NoError(err)
+
+		rel, err := relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.True(rel.RequestedBy)
+		require.False(rel.FollowedBy)
+
+		rel, err = relationships.AuthorizeFollow(alice, bob)
+		require.NoError(err)
+		require.False(rel.RequestedBy)
+		require.True(rel.FollowedBy)
+
+		var request RelationshipRequest
+		require.NoError(tx.Where("actor_id = ? and target_id = ?", alice.ObjectID, bob.ObjectID).Take(&request).Error)
+		require.EqualValues("accept", request.Action)
+
+		rel, err = relationships.RejectFollow(alice, carol)
+		require.NoError(err)
+		require.False(rel.RequestedBy)
+		require.False(rel.FollowedBy)
+
+		rel, err = relationships.findOrCreate(carol, alice)
+		require.NoError(err)
+		require.False(rel.Requested)
+		require.False(rel.Following)
+
+		var reject RelationshipRequest
+		require.NoError(tx.Where("actor_id = ? and target_id = ?", alice.ObjectID, carol.ObjectID).Take(&reject).Error)
+		require.EqualValues("reject", reject.Action)
+	})
+
+	t.Run("Inbound follows", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		relationships := NewRelationships(tx)
+
+		// authorizing a follow which was never requested does nothing.
+		rel, err := relationships.AuthorizeFollow(alice, bob)
+		require.NoError(err)
+		require.False(rel.FollowedBy)
+
+		_, err = relationships.ReceiveFollow(bob, alice, "https://example.org/bob#follows/1")
+		require.NoError(err)
+		rel, err = relationships.findOrCreate(bob, alice)
+		require.NoError(err)
+		require.True(rel.Following)
+		require.Equal("https://example.org/bob#follows/1", rel.FollowURI)
+
+		actions := func() map[string]RelationshipRequestAction {
+			var requests []RelationshipRequest
+			require.NoError(tx.Where("actor_id = ? and target_id = ?", alice.ObjectID, bob.ObjectID).Find(&requests).Error)
+			actions := make(map[string]RelationshipRequestAction)
+			for _, request := range requests {
+				actions[request.Kind] = request.Action
+			}
+			return actions
+		}
+		require.Equal(map[string]RelationshipRequestAction{"response": "accept"}, actions())
+
+		// following back does not replace the pending accept.
+		_, err = relationships.Follow(alice, bob)
+		require.NoError(err)
+		require.Equal(map[string]RelationshipRequestAction{"response": "accept", "follow": "follow"}, actions())
+
+		// a repeated follow is accepted again, with the id of the new follow.
+		require.NoError(tx.Where("actor_id = ?", alice.ObjectID).Delete(&RelationshipRequest{}).Error)
+		_, err = relationships.ReceiveFollow(bob, alice, "https://example.org/bob#follows/2")
+		require.NoError(err)
+		require.Equal(map[string]RelationshipRequestAction{"response": "accept"}, actions())
+		rel, err = relationships.findOrCreate(bob, alice)
+		require.NoError(err)
+		require.Equal("https://example.org/bob#follows/2", rel.FollowURI)
+	})
+
+	t.Run("Inbound follow from a blocked actor", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		relationships := NewRelationships(tx)
+
+		_, err := relationships.Block(alice, bob)
+		require.NoError(err)
+
+		_, err = relationships.ReceiveFollow(bob, alice, "https://example.org/bob#follows/1")
+		require.NoError(err)
+		rel, err := relationships.findOrCreate(bob, alice)
+		require.NoError(err)
+		require.False(rel.Following)
+		require.False(rel.Requested)
+		rel, err = relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.False(rel.FollowedBy)
+
+		var requests []RelationshipRequest
+		require.NoError(tx.Where("actor_id = ? and target_id = ?", alice.ObjectID, bob.ObjectID).Find(&requests).Error)
+		actions := make(map[string]RelationshipRequestAction)
+		for _, request := range requests {
+			actions[request.Kind] = request.Action
+		}
+		require.Equal(map[string]RelationshipRequestAction{"block": "block", "response": "reject"}, actions)
+	})
+
+	t.Run("Outbound follow requests", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		carol := MockActor(t, tx, "carol", "example.org", RemoteActor)
+		relationships := NewRelationships(tx)
+
+		rel, err := relationships.RequestFollow(alice, bob)
+		require.NoError(err)
+		require.True(rel.Requested)
+		require.False(rel.Following)
+
+		var request RelationshipRequest
+		require.NoError(tx.Where("actor_id = ? and target_id = ?", alice.ObjectID, bob.ObjectID).Take(&request).Error)
+		require.EqualValues("follow", request.Action)
+
+		// the follow is given an id, so bob's answer can refer to it.
+		rel, err = relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.True(strings.HasPrefix(rel.FollowURI, "https://example.com/alice#follows/"), rel.FollowURI)
+
+		// bob accepts
+		_, err = relationships.AuthorizeFollow(bob, alice)
+		require.NoError(err)
+		rel, err = relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.False(rel.Requested)
+		require.True(rel.Following)
+
+		// carol never answers
+		_, err = relationships.RequestFollow(alice, carol)
+		require.NoError(err)
+		require.NoError(relationships.WithdrawFollowRequests(time.Now().Add(time.Minute)))
+		rel, err = relationships.findOrCreate(alice, carol)
+		require.NoError(err)
+		require.False(rel.Requested)
+		require.False(rel.Following)
+
+		var withdraw RelationshipRequest
+		require.NoError(tx.Where("actor_id = ? and target_id = ?", alice.ObjectID, carol.ObjectID).Take(&withdraw).Error)
+		require.EqualValues("unfollow", withdraw.Action)
+
+		// the accepted follow is unaffected
+		rel, err = relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.True(rel.Following)
+
+		// bob removes alice as a follower, which needs no reply.
+		require.NoError(tx.Where("actor_id = ?", alice.ObjectID).Delete(&RelationshipRequest{}).Error)
+		_, err = relationships.RejectFollow(bob, alice)
+		require.NoError(err)
+		rel, err = relationships.findOrCreate(alice, bob)
+		require.NoError(err)
+		require.False(rel.Following)
+		var count int64
+		require.NoError(tx.Model(&RelationshipRequest{}).Where("actor_id = ?", alice.ObjectID).Count(&count).Error)
+		require.Zero(count)
+	})
+
+	t.Run("Following a remote actor schedules a backfill", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		carol := MockActor(t, tx, "carol", "example.com", LocalActor)
+		relationships := NewRelationships(tx)
+
+		_, err := relationships.RequestFollow(alice, bob)
+		require.NoError(err)
+		var count int64
+		require.NoError(tx.Model(&ActorBackfillRequest{}).Count(&count).Error)
+		require.EqualValues(0, count) // not until bob accepts
+
+		_, err = relationships.AuthorizeFollow(bob, alice)
+		require.NoError(err)
+		var requests []ActorBackfillRequest
+		require.NoError(tx.Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(bob.ObjectID, requests[0].ActorID)
+
+		// following a local actor, or a remote actor who follows back, does not
+		_, err = relationships.Follow(alice, carol)
+		require.NoError(err)
+		_, err = relationships.Follow(bob, alice)
+		require.NoError(err)
+		require.NoError(tx.Model(&ActorBackfillRequest{}).Count(&count).Error)
+		require.EqualValues(1, count)
+	})
 }
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"time"
+
+	"github.com/davecheney/pub/internal/snowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// A Report is a complaint about an actor, and optionally some of their statuses.
+// Reports are made by local actors, or received as Flag activities from other servers.
+// Reports which have not had action taken on them form the moderation queue.
+type Report struct {
+	ID        snowflake.ID `gorm:"primarykey;autoIncrement:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ActorID is the ID of the actor who made the report.
+	// For reports received from other servers, this is usually the remote instance's actor.
+	ActorID snowflake.ID `gorm:"not null"`
+	Actor   *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	// TargetID is the ID of the actor being reported.
+	TargetID snowflake.ID `gorm:"not null"`
+	Target   *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	// URI is the id of the Flag activity, for reports received from other servers.
+	URI string `gorm:"size:255"`
+	// Category is the reason for the report.
+	Category ReportCategory `gorm:"not null;default:'other'"`
+	// Comment is the reporter's reason for the report.
+	Comment string `gorm:"type:text"`
+	// Forwarded is true if the report was forwarded to the target's server.
+	Forwarded bool `gorm:"not null;default:false"`
+	// ActionTaken is true once a moderator has resolved the report.
+	ActionTaken   bool `gorm:"not null;default:false"`
+	ActionTakenAt *time.Time
+	// Statuses are the statuses attached to the report.
+	Statuses []*ReportStatus `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+type ReportCategory string
+
+func (ReportCategory) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "mysql", "postgres":
+		return "enum('spam', 'legal', 'violation', 'other')"
+	case "sqlite":
+		return "TEXT"
+	default:
+		return ""
+	}
+}
+
+// ReportStatus is a status attached to a report.
+type ReportStatus struct {
+	ReportID snowflake.ID `gorm:"primarykey;autoIncrement:false"`
+	StatusID snowflake.ID `gorm:"primarykey;autoIncrement:false"`
+	Status   *Status      `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+}
+
+// ReportRequest is a request to forward a report to the target's server as a Flag activity.
+type ReportRequest struct {
+	Request
+
+	// ReportID is the ID of the report to forward.
+	ReportID snowflake.ID `gorm:"uniqueIndex;not null"`
+	Report   *Report      `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+}
+
+// PreloadReport preloads all of a Report's relations and associations.
+func PreloadReport(query *gorm.DB) *gorm.DB {
+	return query.Preload("Actor").Preload("Actor.Object").
+		Preload("Target").Preload("Target.Object").
+		Preload("Statuses").Preload("Statuses.Status").Preload("Statuses.Status.Object")
+}
+
+type Reports struct {
+	db *gorm.DB
+}
+
+func NewReports(db *gorm.DB) *Reports {
+	return &Reports{db: db}
+}
+
+// Create creates a report by the local actor about the target and its statuses.
+// If forward is true and the target is remote, the report is also forwarded to the
+// target's server.
+func (r *Reports) Create(actor, target *Actor, statuses []*Status, category ReportCategory, comment string, forward bool) (*Report, error) {
+	report := &Report{
+		ActorID:   actor.ObjectID,
+		TargetID:  target.ObjectID,
+		Category:  category,
+		Comment:   comment,
+		Forwarded: forward && target.IsRemote(),
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := r.create(tx, report, statuses); err != nil {
+			return err
+		}
+		if !report.Forwarded {
+			return nil
+		}
+		return tx.Create(&ReportRequest{ReportID: report.ID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.FindByID(report.ID)
+}
+
+// Flag records a report about the target and its statuses received from another
+// server as a Flag activity.
+func (r *Reports) Flag(actor, target *Actor, statuses []*Status, comment, uri string) (*Report, error) {
+	report := &Report{
+		ActorID:  actor.ObjectID,
+		TargetID: target.ObjectID,
+		URI:      uri,
+		Category: "other",
+		Comment:  comment,
+	}
+	if err := r.create(r.db, report, statuses); err != nil {
+		return nil, err
+	}
+	return r.FindByID(report.ID)
+}
+
+func (r *Reports) create(tx *gorm.DB, report *Report, statuses []*Status) error {
+	report.ID = snowflake.Now()
+	for _, st := range statuses {
+		report.Statuses = append(report.Statuses, &ReportStatus{StatusID: st.ObjectID})
+	}
+	return tx.Create(report).Error
+}
+
+// FindByID returns the report with the given ID.
+func (r *Reports) FindByID(id snowflake.ID) (*Report, error) {
+	var report Report
+	if err := r.db.Scopes(PreloadReport).Take(&report, id).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Unresolved returns the reports which have not yet had action taken on them, oldest first.
+func (r *Reports) Unresolved() ([]*Report, error) {
+	var reports []*Report
+	return reports, r.db.Scopes(PreloadReport).Where("action_taken = ?", false).Order("id").Find(&reports).Error
+}
+
+// Resolve records that a moderator has taken action on the report.
+func (r *Reports) Resolve(id snowflake.ID) error {
+	res := r.db.Model(&Report{ID: id}).Where("action_taken = ?", false).Updates(map[string]any{
+		"action_taken":    true,
+		"action_taken_at": time.Now(),
+	})
+	if err := res.Error; err != nil {
+		return err
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestReports(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Report a remote actor and forward it", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.org", RemoteActor)
+		status := MockStatus(t, tx, bob, "Buy my stuff")
+
+		report, err := NewReports(tx).Create(alice, bob, []*Status{status}, "spam", "spammer", true)
+		require.NoError(err)
+		require.True(report.Forwarded)
+		require.EqualValues("spam", report.Category)
+		require.Equal(bob.ObjectID, report.Target.ObjectID)
+		require.Len(report.Statuses, 1)
+		require.Equal(status.URI(), report.Statuses[0].Status.URI())
+
+		var request ReportRequest
+		require.NoError(tx.Where("report_id = ?", report.ID).First(&request).Error)
+	})
+
+	t.Run("Reports about local actors are not forwarded", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		bob := MockActor(t, tx, "bob", "example.com", LocalActor)
+
+		report, err := NewReports(tx).Create(alice, bob, nil, "other", "", true)
+		require.NoError(err)
+		require.False(report.Forwarded)
+
+		var count int64
+		require.NoError(tx.Model(&ReportRequest{}).Count(&count).Error)
+		require.EqualValues(0, count)
+	})
+
+	t.Run("Flags land in the moderation queue", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockActor(t, tx, "instance", "example.org", RemoteActor)
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		status := MockStatus(t, tx, alice, "Something objectionable")
+
+		reports := NewReports(tx)
+		flag, err := reports.Flag(instance, alice, []*Status{status}, "please look at this", "https://example.org/flags/1")
+		require.NoError(err)
+		require.Equal("https://example.org/flags/1", flag.URI)
+		require.False(flag.Forwarded)
+
+		unresolved, err := reports.Unresolved()
+		require.NoError(err)
+		require.Len(unresolved, 1)
+		require.Equal(flag.ID, unresolved[0].ID)
+		require.Equal("please look at this", unresolved[0].Comment)
+
+		require.NoError(reports.Resolve(flag.ID))
+		unresolved, err = reports.Unresolved()
+		require.NoError(err)
+		require.Empty(unresolved)
+		resolved, err := reports.FindByID(flag.ID)
+		require.NoError(err)
+		require.True(resolved.ActionTaken)
+		require.NotNil(resolved.ActionTakenAt)
+
+		// a report can only be resolved once
+		require.ErrorIs(reports.Resolve(flag.ID), gorm.ErrRecordNotFound)
+	})
+}
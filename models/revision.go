@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/davecheney/pub/internal/algorithms"
+	"github.com/davecheney/pub/internal/snowflake"
+	"gorm.io/gorm"
+)
+
+// A StatusRevision is a version of a status. Revisions are recorded when a status
+// is edited, the first revision being the status as originally published.
+type StatusRevision struct {
+	ID          uint32             `gorm:"primarykey"`
+	StatusID    snowflake.ID       `gorm:"not null;index"`
+	Status      *Status            `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	CreatedAt   time.Time          `gorm:"not null"`
+	Content     string             `gorm:"type:text"`
+	Summary     string             `gorm:"type:text"`
+	Sensitive   bool               `gorm:"not null;default:false"`
+	Attachments []StatusAttachment `gorm:"serializer:json"`
+}
+
+// MediaAttachments returns the attachments of the revision.
+func (r *StatusRevision) MediaAttachments() []*Attachment {
+	return algorithms.Map(r.Attachments, toAttachment)
+}
+
+// revisionOf returns the revision described by the properties of a Note or Question.
+func revisionOf(props map[string]any) (*StatusRevision, error) {
+	b, err := json.Marshal(props)
+	if err != nil {
+		return nil, err
+	}
+	var obj struct {
+		Content    string             `json:"content"`
+		Summary    string             `json:"summary"`
+		Sensitive  bool               `json:"sensitive"`
+		Attachment []StatusAttachment `json:"attachment"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	return &StatusRevision{
+		Content:     obj.Content,
+		Summary:     obj.Summary,
+		Sensitive:   obj.Sensitive,
+		Attachments: obj.Attachment,
+	}, nil
+}
+
+// changed reports whether the revision differs from the previous revision.
+func (r *StatusRevision) changed(prev *StatusRevision) bool {
+	switch {
+	case r.Content != prev.Content, r.Summary != prev.Summary, r.Sensitive != prev.Sensitive:
+		return true
+	case len(r.Attachments) == 0 && len(prev.Attachments) == 0:
+		return false
+	default:
+		return !reflect.DeepEqual(r.Attachments, prev.Attachments)
+	}
+}
+
+// maybeSaveRevision records a revision if the Note, or Question, has been edited.
+// The first time a status is edited, its original version is recorded as well.
+func (o *Object) maybeSaveRevision(tx *gorm.DB) error {
+	if o.previous == nil {
+		// the object is new
+		return nil
+	}
+	prev, err := revisionOf(o.previous.Properties)
+	if err != nil {
+		return err
+	}
+	next, err := revisionOf(o.Properties)
+	if err != nil {
+		return err
+	}
+	if !next.changed(prev) {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&StatusRevision{}).Where("status_id = ?", o.previous.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		prev.StatusID = o.previous.ID
+		prev.CreatedAt = timeFromAny(o.previous.Properties["updated"], o.previous.Properties["published"])
+		if err := tx.Create(prev).Error; err != nil {
+			return err
+		}
+	}
+	next.StatusID = o.previous.ID
+	next.CreatedAt = timeFromAny(o.Properties["updated"])
+	return tx.Create(next).Error
+}
+
+// timeFromAny returns the first of the values which is a valid RFC3339 timestamp,
+// or the current time if none are.
+func timeFromAny(values ...any) time.Time {
+	for _, v := range values {
+		if t, err := time.Parse(time.RFC3339, stringFromAny(v)); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// Revisions returns the revisions of the status, oldest first.
+func (s *Statuses) Revisions(status *Status) ([]*StatusRevision, error) {
+	var revisions []*StatusRevision
+	if err := s.db.Where("status_id = ?", status.ObjectID).Order("created_at, id").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/davecheney/pub/internal/algorithms"
@@ -32,8 +35,9 @@ type Status struct {
 	ReblogsCount     int        `gorm:"not null;default:0"`
 	FavouritesCount  int        `gorm:"not null;default:0"`
 	ReblogID         *snowflake.ID
-	Reblog           *Status   `gorm:"constraint:OnDelete:CASCADE;<-:false;"` // don't update reblog on status update
-	Reaction         *Reaction `gorm:"constraint:OnDelete:CASCADE;<-:false;"` // don't update reaction on status update
+	Reblog           *Status     `gorm:"constraint:OnDelete:CASCADE;<-:false;"`                     // don't update reblog on status update
+	Reaction         *Reaction   `gorm:"constraint:OnDelete:CASCADE;<-:false;"`                     // don't update reaction on status update
+	Poll             *StatusPoll `gorm:"foreignKey:StatusID;constraint:OnDelete:CASCADE;<-:false;"` // polls are saved from the Question object
 }
 
 type StatusObject struct {
@@ -49,6 +53,10 @@ type StatusObject struct {
 		Summary    string             `json:"summary"`
 		Attachment []StatusAttachment `json:"attachment"`
 		Tag        StatusTags         `json:"tag"`
+		Source     struct {
+			Content   string `json:"content"`
+			MediaType string `json:"mediaType"`
+		} `json:"source"`
 	} `gorm:"serializer:json;not null"`
 }
 
@@ -101,31 +109,32 @@ func (st *Status) AfterCreate(tx *gorm.DB) error {
 }
 
 func (st *Status) Attachments() []*Attachment {
-	return algorithms.Map(st.Object.Properties.Attachment, func(a StatusAttachment) *Attachment {
-		return &Attachment{
-			MediaType: a.MediaType,
-			URL:       a.URL,
-			Name:      a.Name,
-			Width:     a.Width,
-			Height:    a.Height,
-			Blurhash:  a.Blurhash,
-			FocalPoint: FocalPoint{
-				X: func() float64 {
-					if len(a.FocalPoint) == 0 {
-						return 0
-					}
-					return a.FocalPoint[0]
-				}(),
-				Y: func() float64 {
-					if len(a.FocalPoint) < 2 {
-						return 0
-					}
-					return a.FocalPoint[1]
-				}(),
-			},
-		}
-	})
-
+	return algorithms.Map(st.Object.Properties.Attachment, toAttachment)
+}
+
+func toAttachment(a StatusAttachment) *Attachment {
+	return &Attachment{
+		MediaType: a.MediaType,
+		URL:       a.URL,
+		Name:      a.Name,
+		Width:     a.Width,
+		Height:    a.Height,
+		Blurhash:  a.Blurhash,
+		FocalPoint: FocalPoint{
+			X: func() float64 {
+				if len(a.FocalPoint) == 0 {
+					return 0
+				}
+				return a.FocalPoint[0]
+			}(),
+			Y: func() float64 {
+				if len(a.FocalPoint) < 2 {
+					return 0
+				}
+				return a.FocalPoint[1]
+			}(),
+		},
+	}
 }
 
 func (st *Status) Language() string {
@@ -136,6 +145,22 @@ func (st *Status) Note() string {
 	return st.Object.Properties.Content
 }
 
+// Source returns the plain text the status was written as. Statuses which
+// did not record their source have the markup removed from their content.
+func (st *Status) Source() string {
+	if source := st.Object.Properties.Source; source.Content != "" && source.MediaType == "text/plain" {
+		return source.Content
+	}
+	text := paragraphRegexp.ReplaceAllString(st.Note(), "\n")
+	text = tagRegexp.ReplaceAllString(text, "")
+	return html.UnescapeString(strings.TrimSpace(text))
+}
+
+var (
+	paragraphRegexp = regexp.MustCompile(`(?i)<br\s*/?>|</p>\s*<p>`)
+	tagRegexp       = regexp.MustCompile(`<[^>]*>`)
+)
+
 func (st *Status) Sensitive() bool {
 	return st.Object.Properties.Sensitive
 }
@@ -233,8 +258,12 @@ type StatusPoll struct {
 	StatusID   snowflake.ID `gorm:"primarykey;autoIncrement:false"`
 	ExpiresAt  time.Time
 	Multiple   bool
-	VotesCount int                `gorm:"not null;default:0"`
-	Options    []StatusPollOption `gorm:"constraint:OnDelete:CASCADE;"`
+	VotesCount int `gorm:"not null;default:0"`
+	// VotersCount is the number of actors who have voted.
+	VotersCount int                `gorm:"not null;default:0"`
+	Options     []StatusPollOption `gorm:"constraint:OnDelete:CASCADE;"`
+	// Votes are the votes of the current user, when preloaded.
+	Votes []StatusPollVote `gorm:"constraint:OnDelete:CASCADE;"`
 }
 
 func (st *StatusPoll) AfterCreate(tx *gorm.DB) error {
@@ -256,6 +285,37 @@ type StatusPollOption struct {
 	Count        int    `gorm:"not null;default:0"`
 }
 
+// StatusPollVote is an actor's vote for an option of a poll.
+type StatusPollVote struct {
+	StatusPollID snowflake.ID      `gorm:"primarykey;autoIncrement:false"`
+	ActorID      snowflake.ID      `gorm:"primarykey;autoIncrement:false"`
+	Actor        *Actor            `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	OptionID     uint32            `gorm:"primarykey;autoIncrement:false"`
+	Option       *StatusPollOption `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+}
+
+// StatusPollVoteRequest is a request to send a local actor's votes to the
+// server of a remote poll.
+type StatusPollVoteRequest struct {
+	Request
+
+	// StatusID is the ID of the status of the poll.
+	StatusID snowflake.ID `gorm:"uniqueIndex:idx_status_actor;not null"`
+	Status   *Status      `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	// ActorID is the ID of the local actor who voted.
+	ActorID snowflake.ID `gorm:"uniqueIndex:idx_status_actor;not null"`
+	Actor   *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+}
+
+// StatusRepliesRequest is a request to fetch the replies to a remote status.
+type StatusRepliesRequest struct {
+	Request
+	// StatusID is the ID of the status whose replies are fetched.
+	StatusID snowflake.ID `gorm:"uniqueIndex;not null;"`
+	// Status is the status whose replies are fetched.
+	Status *Status `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+}
+
 type StatusMention struct {
 	StatusID snowflake.ID `gorm:"primarykey;autoIncrement:false"`
 	ActorID  snowflake.ID `gorm:"primarykey;autoIncrement:false"`
@@ -335,59 +395,340 @@ func (s *Statuses) FindByID(id snowflake.ID) (*Status, error) {
 	return &status, err
 }
 
-func (s *Statuses) Create(actor *Actor, parent *Status, visibility Visibility, sensitive bool, spoilterText, language, note string) (*Status, error) {
+// Create creates a new status authored by the local actor, optionally in reply to parent.
+// The status' Note is stored as an Object, and a delivery request is queued for each
+// remote inbox the Note is addressed to. If poll is not nil, the status is a Question
+// with the poll's options.
+func (s *Statuses) Create(actor *Actor, parent *Status, visibility Visibility, sensitive bool, spoilerText, language, note string, poll *StatusPoll) (*Status, error) {
+	if visibility == "" {
+		visibility = "public"
+	}
 	createdAt := time.Now()
-	conv := conversation(parent, visibility)
 	id := snowflake.TimeToID(createdAt)
-	status := Status{
-		ObjectID:     id,
-		UpdatedAt:    createdAt,
-		ActorID:      actor.ObjectID,
-		Actor:        actor,
-		Conversation: conv,
-		InReplyToID: func() *snowflake.ID {
-			if parent != nil {
-				return &parent.ObjectID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		content, tags, mentions := formatNote(NewActors(tx), actor, note)
+		if parent != nil && parent.Actor != nil && parent.ActorID != actor.ObjectID {
+			mentions = append(mentions, parent.Actor)
+		}
+		to, cc := addressing(actor, visibility, mentions)
+		props := map[string]any{
+			"id":           fmt.Sprintf("https://%s/u/%s/statuses/%d", actor.Domain, actor.Name, id),
+			"type":         "Note",
+			"attributedTo": actor.URI(),
+			"published":    createdAt.UTC().Format(time.RFC3339),
+			"url":          fmt.Sprintf("%s/%d", actor.URL(), id),
+			"to":           to,
+			"cc":           cc,
+			"sensitive":    sensitive,
+			"content":      content,
+			"tag":          tags,
+			"attachment":   []any{},
+			"source": map[string]any{
+				"content":   note,
+				"mediaType": "text/plain",
+			},
+		}
+		if spoilerText != "" {
+			props["summary"] = spoilerText
+		}
+		if language != "" {
+			props["contentMap"] = map[string]any{language: content}
+		}
+		if parent != nil {
+			props["inReplyTo"] = parent.URI()
+		}
+		if poll != nil {
+			props["type"] = "Question"
+			props["endTime"] = poll.ExpiresAt.UTC().Format(time.RFC3339)
+			props["votersCount"] = 0
+			options := algorithms.Map(poll.Options, func(option StatusPollOption) any {
+				return map[string]any{
+					"type": "Note",
+					"name": option.Title,
+					"replies": map[string]any{
+						"type":       "Collection",
+						"totalItems": 0,
+					},
+				}
+			})
+			if poll.Multiple {
+				props["anyOf"] = options
+			} else {
+				props["oneOf"] = options
+			}
+		}
+		obj := &Object{
+			ID:         id, // populate ID so that it matches the URI, otherwise it will be generated from snowflake.FromDate.
+			Properties: props,
+		}
+		if err := tx.Create(obj).Error; err != nil {
+			return err
+		}
+		// The Object's AfterSave hook has created the Status, but the visibility it
+		// infers from the addressing is lossy, so record what the author asked for.
+		if err := tx.Model(&Status{ObjectID: id}).UpdateColumn("visibility", visibility).Error; err != nil {
+			return err
+		}
+		recipients, err := NewActors(tx).Recipients(actor, append(to, cc...))
+		if err != nil {
+			return err
+		}
+		for _, recipient := range recipients {
+			if err := tx.Create(&ActivitypubOutboxRequest{
+				ObjectID: id,
+				ActorID:  recipient.ObjectID,
+				Action:   "create",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.FindByID(id)
+}
+
+// RefreshReplies schedules a fetch of the replies to the remote status, and their replies
+// in turn, so its thread is as complete as it is on the author's server.
+func (s *Statuses) RefreshReplies(status *Status) error {
+	db := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "status_id"}},
+		DoNothing: true, // a fetch is already pending
+	})
+	return db.Create(&StatusRepliesRequest{StatusID: status.ObjectID}).Error
+}
+
+// ErrReblogNotEditable is returned when editing a reblog, which has no content of its own.
+var ErrReblogNotEditable = errors.New("reblogs cannot be edited")
+
+// Edit replaces the content of the local status, recording the previous version
+// and queuing an update request for each remote inbox the edited Note is addressed to.
+func (s *Statuses) Edit(status *Status, sensitive bool, spoilerText, language, note string) (*Status, error) {
+	if status.ReblogID != nil {
+		return nil, ErrReblogNotEditable
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var obj Object
+		if err := tx.Take(&obj, status.ObjectID).Error; err != nil {
+			return err
+		}
+		author, err := NewActors(tx).FindByURI(stringFromAny(obj.Properties["attributedTo"]))
+		if err != nil {
+			return err
+		}
+		content, tags, mentions := formatNote(NewActors(tx), author, note)
+		if status.InReplyToActorID != nil && *status.InReplyToActorID != author.ObjectID {
+			var parent Actor
+			if err := tx.Scopes(PreloadActor).Take(&parent, *status.InReplyToActorID).Error; err != nil {
+				return err
 			}
-			return nil
-		}(),
-		InReplyToActorID: func() *snowflake.ID {
-			if parent != nil {
-				return &parent.ActorID
+			mentions = append(mentions, &parent)
+		}
+		// address the Note afresh so that actors newly mentioned receive the edit.
+		to, cc := addressing(author, status.Visibility, mentions)
+		obj.Properties["to"] = to
+		obj.Properties["cc"] = cc
+		obj.Properties["content"] = content
+		obj.Properties["tag"] = tags
+		obj.Properties["sensitive"] = sensitive
+		obj.Properties["source"] = map[string]any{
+			"content":   note,
+			"mediaType": "text/plain",
+		}
+		obj.Properties["updated"] = time.Now().UTC().Format(time.RFC3339)
+		delete(obj.Properties, "summary")
+		if spoilerText != "" {
+			obj.Properties["summary"] = spoilerText
+		}
+		delete(obj.Properties, "contentMap")
+		if language != "" {
+			obj.Properties["contentMap"] = map[string]any{language: content}
+		}
+		// The Object's AfterSave hook records the revision.
+		if err := tx.Save(&obj).Error; err != nil {
+			return err
+		}
+		recipients, err := NewActors(tx).Recipients(author, append(anyToSlice(obj.Properties["to"]), anyToSlice(obj.Properties["cc"])...))
+		if err != nil {
+			return err
+		}
+		for _, recipient := range recipients {
+			if err := tx.Create(&ActivitypubOutboxRequest{
+				ObjectID: obj.ID,
+				ActorID:  recipient.ObjectID,
+				Action:   "update",
+			}).Error; err != nil {
+				return err
 			}
-			return nil
-		}(),
-		// URI:         fmt.Sprintf("https://%s/users/%s/%d", actor.Domain, actor.Name, id),
-		// Sensitive:   sensitive,
-		// SpoilerText: spoilterText,
-		Visibility: visibility,
-		// Language:    language,
-		// Note:        note,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &status, s.db.Create(&status).Error
+	return s.FindByID(status.ObjectID)
 }
 
-// conversation returns the conversation of the parent, or a new conversation if parent is nil.
-func conversation(parent *Status, visibility Visibility) *Conversation {
-	if parent != nil {
-		return parent.Conversation
+// Delete deletes the local status, replacing its Note with a Tombstone and queuing
+// a delete request for each remote inbox the Note was addressed to. Deleting a reblog
+// undoes it.
+func (s *Statuses) Delete(status *Status) error {
+	if status.ReblogID != nil {
+		reblogged, err := s.FindByID(*status.ReblogID)
+		if err != nil {
+			return err
+		}
+		var actor Actor
+		if err := s.db.Scopes(PreloadActor).Take(&actor, status.ActorID).Error; err != nil {
+			return err
+		}
+		_, err = NewReactions(s.db).Unreblog(reblogged, &actor)
+		return err
 	}
-	return &Conversation{
-		Visibility: visibility,
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var obj Object
+		if err := tx.Take(&obj, status.ObjectID).Error; err != nil {
+			return err
+		}
+		author, err := NewActors(tx).FindByURI(stringFromAny(obj.Properties["attributedTo"]))
+		if err != nil {
+			return err
+		}
+		recipients, err := NewActors(tx).Recipients(author, append(anyToSlice(obj.Properties["to"]), anyToSlice(obj.Properties["cc"])...))
+		if err != nil {
+			return err
+		}
+		// there is no point creating something we are about to delete.
+		if err := tx.Where("object_id = ? and action = ?", obj.ID, "create").Delete(&ActivitypubOutboxRequest{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&Status{ObjectID: status.ObjectID}).Error; err != nil {
+			return err
+		}
+		obj.Type = "Tombstone"
+		obj.Properties = map[string]any{
+			"id":           obj.URI,
+			"type":         obj.Type,
+			"formerType":   obj.Properties["type"],
+			"attributedTo": author.URI(),
+			"published":    obj.Properties["published"],
+			"deleted":      time.Now().UTC().Format(time.RFC3339),
+		}
+		if err := tx.Save(&obj).Error; err != nil {
+			return err
+		}
+		for _, recipient := range recipients {
+			if err := tx.Create(&ActivitypubOutboxRequest{
+				ObjectID: obj.ID,
+				ActorID:  recipient.ObjectID,
+				Action:   "delete",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addressing returns the to and cc fields for a Note of the given visibility.
+// https://docs.joinmastodon.org/spec/activitypub/#as
+func addressing(actor *Actor, visibility Visibility, mentions []*Actor) ([]any, []any) {
+	const public = "https://www.w3.org/ns/activitystreams#Public"
+	followers := actor.URI() + "/followers"
+	var to, cc []any
+	switch visibility {
+	case "public":
+		to = []any{public}
+		cc = []any{followers}
+	case "unlisted":
+		to = []any{followers}
+		cc = []any{public}
+	case "private", "limited":
+		to = []any{followers}
+	}
+	for _, mention := range mentions {
+		switch visibility {
+		case "direct":
+			to = append(to, mention.URI())
+		default:
+			cc = append(cc, mention.URI())
+		}
+	}
+	if to == nil {
+		to = []any{}
+	}
+	if cc == nil {
+		cc = []any{}
+	}
+	return to, cc
+}
+
+var (
+	mentionRegexp = regexp.MustCompile(`(^|\s)@(\w+)(?:@([\w.-]+\w))?`)
+	hashtagRegexp = regexp.MustCompile(`(^|\s)#(\w+)`)
+)
+
+// formatNote converts the plain text note into HTML content, linking mentions of
+// known actors and hashtags. It returns the content, the Note's tags, and the
+// actors mentioned.
+func formatNote(actors *Actors, author *Actor, note string) (string, []any, []*Actor) {
+	tags := []any{}
+	var mentions []*Actor
+	text := html.EscapeString(note)
+	text = mentionRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		parts := mentionRegexp.FindStringSubmatch(m)
+		domain := parts[3]
+		if domain == "" {
+			domain = author.Domain
+		}
+		mentioned, err := actors.Find(parts[2], domain)
+		if err != nil {
+			// unknown actor, leave the text alone.
+			return m
+		}
+		mentions = append(mentions, mentioned)
+		tags = append(tags, map[string]any{
+			"type": "Mention",
+			"href": mentioned.URI(),
+			"name": "@" + mentioned.Name + "@" + mentioned.Domain,
+		})
+		return fmt.Sprintf(`%s<span class="h-card"><a href="%s" class="u-url mention">@<span>%s</span></a></span>`, parts[1], mentioned.URL(), mentioned.Name)
+	})
+	text = hashtagRegexp.ReplaceAllStringFunc(text, func(m string) string {
+		parts := hashtagRegexp.FindStringSubmatch(m)
+		href := fmt.Sprintf("https://%s/tags/%s", author.Domain, strings.ToLower(parts[2]))
+		tags = append(tags, map[string]any{
+			"type": "Hashtag",
+			"href": href,
+			"name": "#" + parts[2],
+		})
+		return fmt.Sprintf(`%s<a href="%s" class="mention hashtag" rel="tag">#<span>%s</span></a>`, parts[1], href, parts[2])
+	})
+	var sb strings.Builder
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if para == "" {
+			continue
+		}
+		sb.WriteString("<p>")
+		sb.WriteString(strings.ReplaceAll(para, "\n", "<br />"))
+		sb.WriteString("</p>")
 	}
+	return sb.String(), tags, mentions
 }
 
 // PreloadStatus preloads all of a Status' relations and associations.
 func PreloadStatus(query *gorm.DB) *gorm.DB {
 	// return query.Preload("Attachments").
-	// Preload("Poll").Preload("Poll.Options").
 	// Preload("Mentions").Preload("Mentions.Actor").Preload("Mentions.Actor.Object").
 	return query.Preload("Object").Preload("Actor").Preload("Actor.Object").
+		Preload("Poll").Preload("Poll.Options", orderPollOptions).
 		// Preload("Tags").Preload("Tags.Tag").
 		Preload("Reblog").Preload("Reblog.Object").
-		Preload("Reblog.Actor").Preload("Reblog.Actor.Object")
+		Preload("Reblog.Actor").Preload("Reblog.Actor.Object").
+		Preload("Reblog.Poll").Preload("Reblog.Poll.Options", orderPollOptions)
 	// Preload("Reblog.Attachments").
-	// Preload("Reblog.Poll").Preload("Reblog.Poll.Options").
 	// Preload("Reblog.Mentions").Preload("Reblog.Mentions.Actor").Preload("Reblog.Mentions.Actor.Object").
 	// Preload("Reblog.Tags").Preload("Reblog.Tags.Tag")
 }
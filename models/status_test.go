@@ -1,5 +1,322 @@
 package models
 
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestStatusesCreate(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Create stores a Note and queues delivery once per shared inbox", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		account, err := NewAccounts(tx).Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		alice := account.Actor
+
+		bob := MockActor(t, tx, "bob", "remote.example", RemoteActor)
+		carol := MockActor(t, tx, "carol", "remote.example", RemoteActor)
+		dave := MockActor(t, tx, "dave", "other.example", RemoteActor)
+		for _, follower := range []*Actor{bob, carol, dave} {
+			_, err := NewRelationships(tx).Follow(follower, alice)
+			require.NoError(err)
+		}
+
+		status, err := NewStatuses(tx).Create(alice, nil, "unlisted", false, "", "en", "Hello #world", nil)
+		require.NoError(err)
+		require.EqualValues("unlisted", status.Visibility)
+		require.Equal(fmt.Sprintf("https://example.com/u/alice/statuses/%d", status.ObjectID), status.URI())
+		require.Contains(status.Note(), `rel="tag"`)
+		require.Len(status.Tag(), 1)
+		require.Equal("#world", status.Tag()[0].Name)
+
+		var obj Object
+		require.NoError(tx.Take(&obj, status.ObjectID).Error)
+		require.Equal("Note", obj.Type)
+		require.Equal(alice.URI(), obj.Properties["attributedTo"])
+		require.Equal([]any{alice.URI() + "/followers"}, obj.Properties["to"])
+		require.Equal([]any{"https://www.w3.org/ns/activitystreams#Public"}, obj.Properties["cc"])
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ?", status.ObjectID).Find(&requests).Error)
+		require.Len(requests, 2) // bob and carol share an inbox
+		for _, req := range requests {
+			require.EqualValues("create", req.Action)
+		}
+	})
+
+	t.Run("Create direct status is only delivered to mentioned actors", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		account, err := NewAccounts(tx).Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		alice := account.Actor
+
+		bob := MockActor(t, tx, "bob", "remote.example", RemoteActor)
+		dave := MockActor(t, tx, "dave", "other.example", RemoteActor)
+		_, err = NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+
+		status, err := NewStatuses(tx).Create(alice, nil, "direct", false, "", "", "@dave@other.example psst", nil)
+		require.NoError(err)
+		require.EqualValues("direct", status.Visibility)
+		require.Contains(status.Note(), dave.URL())
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ?", status.ObjectID).Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(dave.ObjectID, requests[0].ActorID)
+	})
+}
+
+func TestStatusesDelete(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Delete replaces the Note with a Tombstone and queues delivery", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		account, err := NewAccounts(tx).Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		alice := account.Actor
+
+		bob := MockActor(t, tx, "bob", "remote.example", RemoteActor)
+		_, err = NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+
+		statuses := NewStatuses(tx)
+		status, err := statuses.Create(alice, nil, "public", false, "", "", "Hello world", nil)
+		require.NoError(err)
+		require.NoError(statuses.Delete(status))
+
+		_, err = statuses.FindByID(status.ObjectID)
+		require.ErrorIs(err, gorm.ErrRecordNotFound)
+
+		var obj Object
+		require.NoError(tx.Take(&obj, status.ObjectID).Error)
+		require.Equal("Tombstone", obj.Type)
+		require.Equal("Note", obj.Properties["formerType"])
+		require.Equal(status.URI(), obj.Properties["id"])
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ?", status.ObjectID).Find(&requests).Error)
+		require.Len(requests, 1)
+		require.EqualValues("delete", requests[0].Action)
+		require.Equal(bob.ObjectID, requests[0].ActorID)
+	})
+
+	t.Run("Delete of a reblog undoes the reblog", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.org", RemoteActor)
+		bob := MockActor(t, tx, "bob", "example.com", LocalActor)
+		status := MockStatus(t, tx, alice, "Don't stop me now")
+
+		reblog, err := NewReactions(tx).Reblog(status, bob)
+		require.NoError(err)
+
+		statuses := NewStatuses(tx)
+		require.NoError(statuses.Delete(reblog))
+
+		_, err = statuses.FindByID(reblog.ObjectID)
+		require.ErrorIs(err, gorm.ErrRecordNotFound)
+		st, err := statuses.FindByID(status.ObjectID)
+		require.NoError(err)
+		require.EqualValues(0, st.ReblogsCount)
+
+		var request ReactionRequest
+		require.NoError(tx.Where("actor_id = ? AND target_id = ?", bob.ObjectID, status.ObjectID).Take(&request).Error)
+		require.EqualValues("unreblog", request.Action)
+	})
+}
+
+func TestStatusesEdit(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Edit records the original and edited revisions and queues an update", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		instance := MockInstance(t, tx, "example.com")
+		account, err := NewAccounts(tx).Create(instance, "alice", "alice@example.com", "password")
+		require.NoError(err)
+		alice := account.Actor
+
+		bob := MockActor(t, tx, "bob", "remote.example", RemoteActor)
+		_, err = NewRelationships(tx).Follow(bob, alice)
+		require.NoError(err)
+
+		statuses := NewStatuses(tx)
+		status, err := statuses.Create(alice, nil, "public", false, "", "", "Hello wrold", nil)
+		require.NoError(err)
+		_, err = NewReactions(tx).Favourite(status, bob)
+		require.NoError(err)
+
+		edited, err := statuses.Edit(status, true, "typo", "", "Hello world")
+		require.NoError(err)
+		require.Equal("<p>Hello world</p>", edited.Note())
+		require.Equal("Hello world", edited.Source())
+		require.Equal("typo", edited.SpoilerText())
+		require.True(edited.Sensitive())
+		require.EqualValues("public", edited.Visibility)
+		require.EqualValues(1, edited.FavouritesCount)
+		require.Equal(status.ConversationID, edited.ConversationID)
+
+		revisions, err := statuses.Revisions(edited)
+		require.NoError(err)
+		require.Len(revisions, 2)
+		require.Equal("<p>Hello wrold</p>", revisions[0].Content)
+		require.False(revisions[0].Sensitive)
+		require.Equal("<p>Hello world</p>", revisions[1].Content)
+		require.Equal("typo", revisions[1].Summary)
+		require.True(revisions[1].Sensitive)
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ? and action = ?", status.ObjectID, "update").Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(bob.ObjectID, requests[0].ActorID)
+	})
+
+	t.Run("Edit refuses to edit a reblog", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.org", RemoteActor)
+		bob := MockActor(t, tx, "bob", "example.com", LocalActor)
+		status := MockStatus(t, tx, alice, "Under pressure")
+
+		reblog, err := NewReactions(tx).Reblog(status, bob)
+		require.NoError(err)
+
+		_, err = NewStatuses(tx).Edit(reblog, false, "", "", "Under new management")
+		require.ErrorIs(err, ErrReblogNotEditable)
+	})
+
+	t.Run("Edit sends the update to actors newly mentioned", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		carol := MockActor(t, tx, "carol", "remote.example", RemoteActor)
+
+		statuses := NewStatuses(tx)
+		status, err := statuses.Create(alice, nil, "public", false, "", "", "Hello", nil)
+		require.NoError(err)
+
+		_, err = statuses.Edit(status, false, "", "", "Hello @carol@remote.example")
+		require.NoError(err)
+
+		var obj Object
+		require.NoError(tx.Take(&obj, status.ObjectID).Error)
+		require.Contains(obj.Properties["cc"], carol.URI())
+
+		var requests []ActivitypubOutboxRequest
+		require.NoError(tx.Where("object_id = ? and action = ?", status.ObjectID, "update").Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(carol.ObjectID, requests[0].ActorID)
+	})
+
+	t.Run("Updating a remote Note records a revision only when it changes", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		bob := MockActor(t, tx, "bob", "remote.example", RemoteActor)
+		published := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		note := func(content, updated string) *Object {
+			props := map[string]any{
+				"id":           "https://remote.example/users/bob/statuses/1",
+				"type":         "Note",
+				"attributedTo": bob.URI(),
+				"published":    published,
+				"content":      content,
+			}
+			if updated != "" {
+				props["updated"] = updated
+			}
+			return &Object{Properties: props}
+		}
+		// as the inbox saves objects
+		upsert := func(obj *Object) error {
+			return tx.Clauses(
+				clause.Returning{Columns: []clause.Column{{Name: "id"}}},
+				clause.OnConflict{
+					Columns:   []clause.Column{{Name: "uri"}},
+					DoUpdates: clause.AssignmentColumns([]string{"type", "properties"}),
+				}).Save(obj).Error
+		}
+		require.NoError(upsert(note("<p>first</p>", "")))
+		status, err := NewStatuses(tx).FindByURI("https://remote.example/users/bob/statuses/1")
+		require.NoError(err)
+
+		// redelivery of the same version
+		require.NoError(upsert(note("<p>first</p>", "")))
+		revisions, err := NewStatuses(tx).Revisions(status)
+		require.NoError(err)
+		require.Empty(revisions)
+
+		updated := time.Now().UTC().Truncate(time.Second)
+		require.NoError(upsert(note("<p>second</p>", updated.Format(time.RFC3339))))
+		revisions, err = NewStatuses(tx).Revisions(status)
+		require.NoError(err)
+		require.Len(revisions, 2)
+		require.Equal("<p>first</p>", revisions[0].Content)
+		require.Equal("<p>second</p>", revisions[1].Content)
+		require.True(updated.Equal(revisions[1].CreatedAt))
+
+		status, err = NewStatuses(tx).FindByID(status.ObjectID)
+		require.NoError(err)
+		require.Equal("<p>second</p>", status.Note())
+		require.True(updated.Equal(status.UpdatedAt))
+	})
+}
+
+func TestStatusesRefreshReplies(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("RefreshReplies schedules one fetch per status", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		bob := MockActor(t, tx, "bob", "remote.example", RemoteActor)
+		status := MockStatus(t, tx, bob, "What do you all think?")
+
+		statuses := NewStatuses(tx)
+		require.NoError(statuses.RefreshReplies(status))
+		require.NoError(statuses.RefreshReplies(status))
+
+		var requests []StatusRepliesRequest
+		require.NoError(tx.Find(&requests).Error)
+		require.Len(requests, 1)
+		require.Equal(status.ObjectID, requests[0].StatusID)
+
+		// deleting the status deletes the request
+		require.NoError(tx.Delete(status).Error)
+		require.NoError(tx.Find(&requests).Error)
+		require.Empty(requests)
+	})
+}
+
 // func TestStatus(t *testing.T) {
 // 	db := setupTestDB(t)
 
@@ -1,6 +1,137 @@
 package models
 
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/davecheney/pub/internal/snowflake"
+	"gorm.io/gorm"
+)
+
 type Tag struct {
 	ID   uint32 `gorm:"primaryKey"`
 	Name string `gorm:"size:64;uniqueIndex"`
 }
+
+// maxFeaturedTags is the number of hashtags an actor may feature on their profile.
+const maxFeaturedTags = 10
+
+// ErrTooManyFeaturedTags is returned when an actor features more than maxFeaturedTags hashtags.
+var ErrTooManyFeaturedTags = errors.New("too many featured tags")
+
+// A FeaturedTag is a hashtag an actor features on their profile.
+type FeaturedTag struct {
+	ID        uint32 `gorm:"primaryKey"`
+	CreatedAt time.Time
+	ActorID   snowflake.ID `gorm:"uniqueIndex:idx_actor_tag;not null"`
+	Actor     *Actor       `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	TagID     uint32       `gorm:"uniqueIndex:idx_actor_tag;not null"`
+	Tag       *Tag         `gorm:"constraint:OnDelete:CASCADE;<-:false;"`
+	// StatusesCount and LastStatusAt describe the actor's statuses with the tag.
+	// They are calculated when the featured tags are found.
+	StatusesCount int64     `gorm:"-"`
+	LastStatusAt  time.Time `gorm:"-"`
+}
+
+type FeaturedTags struct {
+	db *gorm.DB
+}
+
+func NewFeaturedTags(db *gorm.DB) *FeaturedTags {
+	return &FeaturedTags{db: db}
+}
+
+// Create features the hashtag, with or without its leading #, on the actor's profile.
+func (f *FeaturedTags) Create(actor *Actor, name string) (*FeaturedTag, error) {
+	name = strings.TrimPrefix(name, "#")
+	if name == "" {
+		return nil, errors.New("tag name is empty")
+	}
+	var featured *FeaturedTag
+	err := f.db.Transaction(func(tx *gorm.DB) error {
+		tag := Tag{Name: strings.ToLower(name)}
+		if err := tx.Where(&tag).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		var existing []*FeaturedTag
+		if err := tx.Where("actor_id = ?", actor.ObjectID).Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, ft := range existing {
+			if ft.TagID == tag.ID {
+				// already featured
+				featured = ft
+				featured.Tag = &tag
+				return nil
+			}
+		}
+		if len(existing) >= maxFeaturedTags {
+			return ErrTooManyFeaturedTags
+		}
+		featured = &FeaturedTag{ActorID: actor.ObjectID, TagID: tag.ID, Tag: &tag}
+		return tx.Create(featured).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return featured, f.stats(featured)
+}
+
+// Delete removes the featured tag from the actor's profile.
+func (f *FeaturedTags) Delete(actor *Actor, id uint32) error {
+	res := f.db.Where("actor_id = ?", actor.ObjectID).Delete(&FeaturedTag{ID: id})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindByActor returns the hashtags featured on the actor's profile.
+func (f *FeaturedTags) FindByActor(actor *Actor) ([]*FeaturedTag, error) {
+	var featured []*FeaturedTag
+	if err := f.db.Preload("Tag").Where("actor_id = ?", actor.ObjectID).Order("id").Find(&featured).Error; err != nil {
+		return nil, err
+	}
+	for _, ft := range featured {
+		if err := f.stats(ft); err != nil {
+			return nil, err
+		}
+	}
+	return featured, nil
+}
+
+// stats counts the actor's statuses tagged with the featured tag. Statuses which may
+// carry the tag are found by their properties, then their tags are compared, as tag
+// names are matched without regard to case.
+func (f *FeaturedTags) stats(ft *FeaturedTag) error {
+	pattern := `%"name":"#` + likeEscaper.Replace(strings.ToLower(ft.Tag.Name)) + `"%`
+	var statuses []*Status
+	err := f.db.Preload("Object").
+		Joins("JOIN objects ON objects.id = statuses.object_id").
+		Where("statuses.actor_id = ? AND LOWER(objects.properties) LIKE ? ESCAPE '!'", ft.ActorID, pattern).
+		Find(&statuses).Error
+	if err != nil {
+		return err
+	}
+	ft.StatusesCount = 0
+	for _, status := range statuses {
+		if !slices.ContainsFunc(status.Tag(), func(tag StatusTag) bool {
+			return tag.Type == "Hashtag" && strings.EqualFold(tag.Name, "#"+ft.Tag.Name)
+		}) {
+			continue
+		}
+		ft.StatusesCount++
+		if at := status.ObjectID.ToTime(); at.After(ft.LastStatusAt) {
+			ft.LastStatusAt = at
+		}
+	}
+	return nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, with ! as the escape character.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestFeaturedTags(t *testing.T) {
+	db := setupTestDB(t)
+
+	t.Run("Create and Delete", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		featuredTags := NewFeaturedTags(tx)
+
+		ft, err := featuredTags.Create(alice, "#golang")
+		require.NoError(err)
+		require.Equal("golang", ft.Tag.Name)
+
+		// featuring the same tag twice is idempotent
+		again, err := featuredTags.Create(alice, "golang")
+		require.NoError(err)
+		require.Equal(ft.ID, again.ID)
+
+		featured, err := featuredTags.FindByActor(alice)
+		require.NoError(err)
+		require.Len(featured, 1)
+
+		require.NoError(featuredTags.Delete(alice, ft.ID))
+		require.ErrorIs(featuredTags.Delete(alice, ft.ID), gorm.ErrRecordNotFound)
+
+		featured, err = featuredTags.FindByActor(alice)
+		require.NoError(err)
+		require.Len(featured, 0)
+	})
+
+	t.Run("stats count the actor's statuses with the tag, regardless of case", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		statuses := NewStatuses(tx)
+		for _, note := range []string{"Hello #Go_Lang", "Hello #go_lang", "Hello #goxlang", "Hello #go_language"} {
+			_, err := statuses.Create(alice, nil, "public", false, "", "", note, nil)
+			require.NoError(err)
+		}
+
+		featuredTags := NewFeaturedTags(tx)
+		_, err := featuredTags.Create(alice, "go_lang")
+		require.NoError(err)
+		featured, err := featuredTags.FindByActor(alice)
+		require.NoError(err)
+		require.Len(featured, 1)
+		require.EqualValues(2, featured[0].StatusesCount)
+		require.False(featured[0].LastStatusAt.IsZero())
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		alice := MockActor(t, tx, "alice", "example.com", LocalActor)
+		featuredTags := NewFeaturedTags(tx)
+		for i := 0; i < maxFeaturedTags; i++ {
+			_, err := featuredTags.Create(alice, fmt.Sprintf("tag%d", i))
+			require.NoError(err)
+		}
+		_, err := featuredTags.Create(alice, "onetoomany")
+		require.ErrorIs(err, ErrTooManyFeaturedTags)
+	})
+}
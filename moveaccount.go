@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"gorm.io/gorm"
+)
+
+type SetAccountAliasesCmd struct {
+	Name   string   `required:"" help:"name of the account"`
+	Domain string   `required:"" help:"domain of the account"`
+	Alias  []string `help:"URI of an actor which may move to this account, may be repeated"`
+}
+
+func (s *SetAccountAliasesCmd) Run(ctx *Context) error {
+	db, err := gorm.Open(ctx.Dialector, &ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	actors := models.NewActors(db)
+	actor, err := actors.Find(s.Name, s.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to find actor: %w", err)
+	}
+	return actors.SetAliases(actor, s.Alias)
+}
+
+type MoveAccountCmd struct {
+	Name   string `required:"" help:"name of the account to move"`
+	Domain string `required:"" help:"domain of the account to move"`
+	Target string `required:"" help:"URI of the actor to move to"`
+}
+
+func (m *MoveAccountCmd) Run(ctx *Context) error {
+	db, err := gorm.Open(ctx.Dialector, &ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	actor, err := models.NewActors(db).Find(m.Name, m.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to find actor: %w", err)
+	}
+	account, err := models.NewAccounts(db).AccountForActor(actor)
+	if err != nil {
+		return fmt.Errorf("failed to find account: %w", err)
+	}
+	client, err := activitypub.NewClient(account)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	// the target is fetched to check it lists the account as an alias.
+	actors := models.NewActors(db.WithContext(activitypub.WithClient(context.Background(), client)))
+	target, err := actors.Fetch(m.Target)
+	if err != nil {
+		return fmt.Errorf("failed to fetch target: %w", err)
+	}
+	return actors.Move(account.Actor, target)
+}
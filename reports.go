@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davecheney/pub/internal/snowflake"
+	"github.com/davecheney/pub/models"
+	"gorm.io/gorm"
+)
+
+type ReportsCmd struct {
+	Resolve uint64 `help:"mark the report with this id as resolved"`
+}
+
+func (r *ReportsCmd) Run(ctx *Context) error {
+	db, err := gorm.Open(ctx.Dialector, &ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	reports := models.NewReports(db)
+	if r.Resolve != 0 {
+		return reports.Resolve(snowflake.ID(r.Resolve))
+	}
+	unresolved, err := reports.Unresolved()
+	if err != nil {
+		return err
+	}
+	for _, report := range unresolved {
+		fmt.Printf("%d\t%s\t%s reported %s\t%q\n", report.ID, report.CreatedAt.Format(time.RFC3339), report.Actor.URI(), report.Target.URI(), report.Comment)
+		for _, rs := range report.Statuses {
+			fmt.Printf("\t%s\n", rs.Status.URI())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davecheney/pub/models"
+	"gorm.io/gorm"
+)
+
+type RotateKeysCmd struct {
+	Name   string        `required:"" help:"name of the account"`
+	Domain string        `required:"" help:"domain of the account"`
+	Grace  time.Duration `help:"how long the old key remains verifiable" default:"168h"`
+}
+
+func (r *RotateKeysCmd) Run(ctx *Context) error {
+	db, err := gorm.Open(ctx.Dialector, &ctx.Config)
+	if err != nil {
+		return err
+	}
+
+	actor, err := models.NewActors(db).Find(r.Name, r.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to find actor: %w", err)
+	}
+	accounts := models.NewAccounts(db)
+	account, err := accounts.AccountForActor(actor)
+	if err != nil {
+		return fmt.Errorf("failed to find account: %w", err)
+	}
+	return accounts.RotateKeys(account, r.Grace)
+}
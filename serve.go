@@ -32,10 +32,18 @@ import (
 )
 
 type ServeCmd struct {
-	Addr             string `help:"address to listen" default:"127.0.0.1:9999"`
-	DebugPrintRoutes bool   `help:"print routes to stdout on startup"`
-	LogHTTP          bool   `help:"log HTTP requests"`
-	Funnel           string `help:"hostname for funnel"`
+	Addr                 string        `help:"address to listen" default:"127.0.0.1:9999"`
+	DebugPrintRoutes     bool          `help:"print routes to stdout on startup"`
+	LogHTTP              bool          `help:"log HTTP requests"`
+	Funnel               string        `help:"hostname for funnel"`
+	FollowRequestTimeout time.Duration `help:"withdraw follow requests which are not answered within this time" default:"168h"`
+	InboxWorkers         int           `help:"number of workers processing the inbox queue" default:"4"`
+	DeliveryMaxAge       time.Duration `help:"abandon deliveries which have not succeeded within this time" default:"48h"`
+	SeenActivityTTL      time.Duration `help:"discard activities delivered again within this time" default:"24h"`
+	BackfillItems        int           `help:"number of recent posts fetched from the outbox of a newly followed actor" default:"20"`
+	ThreadFetchDepth     int           `help:"how many replies deep to fetch the thread of a remote status" default:"5"`
+	ThreadFetchLimit     int           `help:"number of replies fetched for the thread of a remote status" default:"100"`
+	AuthorizedFetch      bool          `help:"require HTTP signatures on ActivityPub GET requests, and refuse blocked domains"`
 }
 
 func (s *ServeCmd) Run(ctx *Context) error {
@@ -53,10 +61,13 @@ func (s *ServeCmd) Run(ctx *Context) error {
 		return err
 	}
 
-	client, err := ap.NewClient(&admin)
-	if err != nil {
-		return err
-	}
+	// the client loads the admin's keys for each request, so it signs with
+	// the new keys once they are rotated.
+	client := ap.NewReloadingClient(func() (ap.Signer, error) {
+		var current models.Account
+		err := db.Scopes(models.PreloadAccount).Take(&current, admin.ID).Error
+		return &current, err
+	})
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
@@ -113,6 +124,8 @@ func (s *ServeCmd) Run(ctx *Context) error {
 				r.Patch("/update_credentials", httpx.HandlerFunc(envFn, mastodon.AccountsUpdateCredentials))
 				r.Get("/relationships", httpx.HandlerFunc(envFn, mastodon.RelationshipsShow))
 				r.Get("/familiar_followers", httpx.HandlerFunc(envFn, mastodon.AccountsFamiliarFollowersShow))
+				r.Post("/aliases", httpx.HandlerFunc(envFn, mastodon.AccountsAliasesUpdate))
+				r.Post("/move", httpx.HandlerFunc(envFn, mastodon.AccountsMoveCreate))
 				r.Get("/{id}", httpx.HandlerFunc(envFn, mastodon.AccountsShow))
 				r.Get("/{id}/lists", httpx.HandlerFunc(envFn, mastodon.AccountsShowListMembership)) // todo
 				r.Get("/{id}/statuses", httpx.HandlerFunc(envFn, mastodon.AccountsStatusesShow))
@@ -127,12 +140,18 @@ func (s *ServeCmd) Run(ctx *Context) error {
 				r.Post("/{id}/unblock", httpx.HandlerFunc(envFn, mastodon.BlocksDestroy))
 			})
 			r.Get("/bookmarks", httpx.HandlerFunc(envFn, mastodon.BookmarksIndex))
+			r.Get("/featured_tags", httpx.HandlerFunc(envFn, mastodon.FeaturedTagsIndex))
+			r.Post("/featured_tags", httpx.HandlerFunc(envFn, mastodon.FeaturedTagsCreate))
+			r.Delete("/featured_tags/{id}", httpx.HandlerFunc(envFn, mastodon.FeaturedTagsDestroy))
 			r.Get("/blocks", httpx.HandlerFunc(envFn, mastodon.BlocksIndex))
 			r.Get("/conversations", httpx.HandlerFunc(envFn, mastodon.ConversationsIndex))
 			r.Get("/custom_emojis", httpx.HandlerFunc(envFn, mastodon.EmojisIndex))
 			r.Get("/directory", httpx.HandlerFunc(envFn, mastodon.DirectoryIndex))
 			r.Get("/favourites", httpx.HandlerFunc(envFn, mastodon.FavouritesIndex))
 			r.Get("/filters", httpx.HandlerFunc(envFn, mastodon.FiltersIndex))
+			r.Get("/follow_requests", httpx.HandlerFunc(envFn, mastodon.FollowRequestsIndex))
+			r.Post("/follow_requests/{id}/authorize", httpx.HandlerFunc(envFn, mastodon.FollowRequestsAuthorize))
+			r.Post("/follow_requests/{id}/reject", httpx.HandlerFunc(envFn, mastodon.FollowRequestsReject))
 			r.Get("/lists", httpx.HandlerFunc(envFn, mastodon.ListsIndex))
 			r.Post("/lists", httpx.HandlerFunc(envFn, mastodon.ListsCreate))
 			r.Get("/lists/{id}", httpx.HandlerFunc(envFn, mastodon.ListsShow))
@@ -155,7 +174,10 @@ func (s *ServeCmd) Run(ctx *Context) error {
 			r.Post("/markers", httpx.HandlerFunc(envFn, mastodon.MarkersCreate))
 			r.Get("/mutes", httpx.HandlerFunc(envFn, mastodon.MutesIndex))
 			r.Get("/notifications", httpx.HandlerFunc(envFn, mastodon.NotificationsIndex))
+			r.Get("/polls/{id}", httpx.HandlerFunc(envFn, mastodon.PollsShow))
+			r.Post("/polls/{id}/votes", httpx.HandlerFunc(envFn, mastodon.PollsVotesCreate))
 			r.Get("/preferences", httpx.HandlerFunc(envFn, mastodon.PreferencesShow))
+			r.Post("/reports", httpx.HandlerFunc(envFn, mastodon.ReportsCreate))
 			r.Post("/push/subscription", httpx.HandlerFunc(envFn, mastodon.PushSubscriptionCreate))
 			r.Delete("/push/subscription", httpx.HandlerFunc(envFn, mastodon.PushSubscriptionDestroy))
 			r.Get("/push/subscription", httpx.HandlerFunc(envFn, mastodon.PushSubscriptionShow))
@@ -163,15 +185,19 @@ func (s *ServeCmd) Run(ctx *Context) error {
 			r.Post("/statuses", httpx.HandlerFunc(envFn, mastodon.StatusesCreate))
 			r.Get("/statuses/{id}/context", httpx.HandlerFunc(envFn, mastodon.StatusesContextsShow))
 			r.Get("/statuses/{id}/history", httpx.HandlerFunc(envFn, mastodon.StatusesHistoryShow))
+			r.Get("/statuses/{id}/source", httpx.HandlerFunc(envFn, mastodon.StatusesSourceShow))
 			r.Post("/statuses/{id}/favourite", httpx.HandlerFunc(envFn, mastodon.FavouritesCreate))
 			r.Get("/statuses/{id}/favourited_by", httpx.HandlerFunc(envFn, mastodon.StatusesFavouritesShow))
 			r.Get("/statuses/{id}/reblogged_by", httpx.HandlerFunc(envFn, mastodon.StatusesReblogsShow))
 			r.Post("/statuses/{id}/unfavourite", httpx.HandlerFunc(envFn, mastodon.FavouritesDestroy))
 			r.Post("/statuses/{id}/bookmark", httpx.HandlerFunc(envFn, mastodon.BookmarksCreate))
 			r.Post("/statuses/{id}/unbookmark", httpx.HandlerFunc(envFn, mastodon.BookmarksDestroy))
+			r.Post("/statuses/{id}/pin", httpx.HandlerFunc(envFn, mastodon.PinsCreate))
+			r.Post("/statuses/{id}/unpin", httpx.HandlerFunc(envFn, mastodon.PinsDestroy))
 			r.Post("/statuses/{id}/reblog", httpx.HandlerFunc(envFn, mastodon.StatusesReblogCreate))
 			r.Post("/statuses/{id}/unreblog", httpx.HandlerFunc(envFn, mastodon.StatusesReblogDestroy))
 			r.Get("/statuses/{id}", httpx.HandlerFunc(envFn, mastodon.StatusesShow))
+			r.Put("/statuses/{id}", httpx.HandlerFunc(envFn, mastodon.StatusesUpdate))
 			r.Delete("/statuses/{id}", httpx.HandlerFunc(envFn, mastodon.StatusesDestroy))
 
 			r.Get("/streaming", httpx.HandlerFunc(envFn, mastodon.StreamingWebsocket))
@@ -200,11 +226,12 @@ func (s *ServeCmd) Run(ctx *Context) error {
 		}
 
 		return &activitypub.Env{
-			DB:       db.WithContext(ap.WithClient(r.Context(), client)),
-			Mux:      &mux,
-			Logger:   ctx.Logger,
-			Client:   client,
-			Instance: instance,
+			DB:              db.WithContext(ap.WithClient(r.Context(), client)),
+			Mux:             &mux,
+			Logger:          ctx.Logger,
+			Client:          client,
+			Instance:        instance,
+			AuthorizedFetch: s.AuthorizedFetch,
 		}
 	}
 
@@ -215,15 +242,18 @@ func (s *ServeCmd) Run(ctx *Context) error {
 		r.Post("/revoke", httpx.HandlerFunc(envFn, oauth.TokenDestroy))
 	})
 
-	inbox := activitypub.NewInbox(db)
+	seenActivityTTL := max(s.SeenActivityTTL, activitypub.MinSeenActivityTTL)
+	inbox := activitypub.NewInbox(seenActivityTTL)
 	r.Post("/inbox", httpx.HandlerFunc(envFn, inbox.Create))
 	r.Route("/u/{name}", func(r chi.Router) {
-		r.Get("/", httpx.HandlerFunc(envFn, activitypub.UsersShow))
+		r.Get("/", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.UsersShow)))
 		r.Post("/inbox", httpx.HandlerFunc(envFn, inbox.Create))
-		r.Get("/outbox", httpx.HandlerFunc(envFn, activitypub.Outbox))
-		r.Get("/followers", httpx.HandlerFunc(envFn, activitypub.Followers))
-		r.Get("/following", httpx.HandlerFunc(envFn, activitypub.Following))
-		r.Get("/collections/{collection}", httpx.HandlerFunc(envFn, activitypub.CollectionsShow))
+		r.Get("/outbox", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.Outbox)))
+		r.Get("/followers", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.Followers)))
+		r.Get("/following", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.Following)))
+		r.Get("/collections/{collection}", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.CollectionsShow)))
+		r.Get("/statuses/{id}", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.StatusesShow)))
+		r.Get("/statuses/{id}/activity", httpx.HandlerFunc(envFn, activitypub.AuthorizedFetch(activitypub.StatusesActivityShow)))
 	})
 
 	r.Route("/.well-known", func(r chi.Router) {
@@ -294,8 +324,17 @@ func (s *ServeCmd) Run(ctx *Context) error {
 		return svr.ListenAndServeTLS("server.cert", "server.key")
 	})
 
-	g.Add(workers.NewRelationshipRequestProcessor(ctx.Logger, db))
-	g.Add(workers.NewReactionRequestProcessor(db))
+	g.Add(workers.NewRelationshipRequestProcessor(ctx.Logger, db, s.DeliveryMaxAge))
+	g.Add(workers.NewReactionRequestProcessor(ctx.Logger, db, s.DeliveryMaxAge))
+	g.Add(workers.NewOutboxRequestProcessor(ctx.Logger, db, s.DeliveryMaxAge))
+	g.Add(workers.NewReportRequestProcessor(ctx.Logger, db, s.DeliveryMaxAge))
+	g.Add(workers.NewPollVoteRequestProcessor(ctx.Logger, db, s.DeliveryMaxAge))
+	g.Add(workers.NewInboxRequestProcessor(ctx.Logger, db, client, s.InboxWorkers))
+	g.Add(workers.NewActorBackfillProcessor(ctx.Logger, db, client, s.DeliveryMaxAge, s.BackfillItems))
+	g.Add(workers.NewStatusRepliesProcessor(ctx.Logger, db, client, s.DeliveryMaxAge, s.ThreadFetchDepth, s.ThreadFetchLimit))
+	g.Add(workers.NewFollowRequestTimeoutProcessor(ctx.Logger, db, s.FollowRequestTimeout))
+	g.Add(workers.NewSeenActivityExpiryProcessor(ctx.Logger, db, seenActivityTTL))
+	g.Add(workers.NewRetiredKeyExpiryProcessor(ctx.Logger, db))
 	// g.Add(workers.NewStatusAttachmentRequestProcessor(db))
 
 	// ActorRefreshProcessor needs an admin account to sign the activitypub requests.
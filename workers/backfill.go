@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/activitypub"
+	ap "github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewActorBackfillProcessor fetches up to limit recent posts from the outbox of
+// each remote actor a local actor has started following.
+func NewActorBackfillProcessor(log *slog.Logger, db *gorm.DB, client *ap.Client, maxAge time.Duration, limit int) func(ctx context.Context) error {
+	log = log.With("worker", "ActorBackfillProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := deliver(db, log, maxAge, actorBackfillRequestScope, actorBackfillRequestDomain, func(db *gorm.DB, request *models.ActorBackfillRequest) error {
+				log.Info("processActorBackfillRequest", "request", request.ID, "actor", request.Actor.URI())
+				return activitypub.Backfill(ctx, log.With("request", request.ID), db, client, request.Actor, limit)
+			}); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(30 * time.Second):
+				// continue
+			}
+		}
+	}
+}
+
+func actorBackfillRequestScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Actor").Preload("Actor.Object")
+}
+
+func actorBackfillRequestDomain(request *models.ActorBackfillRequest) string {
+	return request.Actor.Domain
+}
@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewFollowRequestTimeoutProcessor withdraws follow requests made by local actors
+// which have not been accepted or rejected within the timeout.
+func NewFollowRequestTimeoutProcessor(log *slog.Logger, db *gorm.DB, timeout time.Duration) func(ctx context.Context) error {
+	log = log.With("worker", "FollowRequestTimeoutProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := models.NewRelationships(db).WithdrawFollowRequests(time.Now().Add(-timeout)); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Hour):
+				// continue
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/davecheney/pub/activitypub"
+	ap "github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// maxInboxAttempts is the number of times an inbox request is attempted
+// before it is marked dead.
+const maxInboxAttempts = 3
+
+// NewInboxRequestProcessor processes activities queued by the inbox with a pool of workers.
+// Activities from the same actor are processed one at a time in the order they were received.
+func NewInboxRequestProcessor(log *slog.Logger, db *gorm.DB, client *ap.Client, workers int) func(ctx context.Context) error {
+	log = log.With("worker", "InboxRequestProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			var requests []*models.ActivitypubInboxRequest
+			if err := db.Scopes(models.PendingInboxRequests).
+				Where("next_attempt_at <= ?", time.Now()).
+				Limit(100).Find(&requests).Error; err != nil {
+				return err
+			}
+			if err := processInboxRequests(ctx, log, db, client, workers, requests); err != nil {
+				return err
+			}
+			if len(requests) > 0 {
+				// there may be more requests waiting behind those just processed.
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(5 * time.Second):
+				// continue
+			}
+		}
+	}
+}
+
+// processInboxRequests processes the requests concurrently. Each request must
+// be from a different actor.
+func processInboxRequests(ctx context.Context, log *slog.Logger, db *gorm.DB, client *ap.Client, workers int, requests []*models.ActivitypubInboxRequest) error {
+	ch := make(chan *models.ActivitypubInboxRequest)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range ch {
+				if err := processInboxRequest(ctx, log, db, client, request); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	func() {
+		defer close(ch)
+		for _, request := range requests {
+			select {
+			case ch <- request:
+			case err := <-errs:
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// processInboxRequest processes a single request, deleting it on success and
+// recording the failure otherwise. The returned error is only non nil if the
+// database could not be updated.
+func processInboxRequest(ctx context.Context, log *slog.Logger, db *gorm.DB, client *ap.Client, request *models.ActivitypubInboxRequest) error {
+	start := time.Now()
+	err := activitypub.ProcessActivity(ctx, log.With("request", request.ID), db, client, request.Activity)
+	if err == nil {
+		return db.Delete(request).Error
+	}
+	log.Info("processInboxRequest", "request", request.ID, "actor", request.ActorURI, "activity", request.ActivityID, "attempts", request.Attempts+1, "error", err)
+	updates := map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_attempt":    start,
+		"last_result":     err.Error(),
+		"next_attempt_at": start.Add(backoff(request.Attempts + 1)),
+	}
+	if request.Attempts+1 >= maxInboxAttempts {
+		updates["state"] = "dead"
+	}
+	return db.Model(request).Updates(updates).Error
+}
@@ -0,0 +1,95 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/davecheney/pub/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewOutboxRequestProcessor handles delivery of locally authored objects to remote inboxes.
+func NewOutboxRequestProcessor(log *slog.Logger, db *gorm.DB, maxAge time.Duration) func(ctx context.Context) error {
+	log = log.With("worker", "OutboxRequestProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := deliver(db, log, maxAge, outboxRequestScope, outboxRequestDomain, func(db *gorm.DB, request *models.ActivitypubOutboxRequest) error {
+				return processOutboxRequest(log, db, request)
+			}); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(30 * time.Second):
+				// continue
+			}
+		}
+	}
+}
+
+func outboxRequestScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Object").Preload("Actor").Preload("Actor.Object")
+}
+
+func outboxRequestDomain(request *models.ActivitypubOutboxRequest) string {
+	return request.Actor.Domain
+}
+
+func processOutboxRequest(log *slog.Logger, db *gorm.DB, request *models.ActivitypubOutboxRequest) error {
+	log.Info("processOutboxRequest", "request", request.ID, "object", request.Object.URI, "target", request.Actor.URI(), "action", request.Action)
+	author, err := outboxRequestAuthor(db, request)
+	if err != nil {
+		return err
+	}
+	account, err := models.NewAccounts(db).AccountForActor(author)
+	if err != nil {
+		return err
+	}
+	switch request.Action {
+	case "create":
+		return activitypub.Create(db.Statement.Context, account, request.Object.Properties, request.Actor)
+	case "delete":
+		return activitypub.Delete(db.Statement.Context, account, request.Object.Properties, request.Actor)
+	case "update":
+		if isStatusObject(request.Object) {
+			return activitypub.UpdateObject(db.Statement.Context, account, request.Object.Properties, request.Actor)
+		}
+		return activitypub.Update(db.Statement.Context, account, request.Actor)
+	case "move":
+		return activitypub.Move(db.Statement.Context, account, request.Actor)
+	default:
+		return fmt.Errorf("unknown action %q", request.Action)
+	}
+}
+
+// outboxRequestAuthor returns the local actor on whose behalf the request is sent.
+func outboxRequestAuthor(db *gorm.DB, request *models.ActivitypubOutboxRequest) (*models.Actor, error) {
+	switch {
+	case request.Action == "move", request.Action == "update" && !isStatusObject(request.Object):
+		// the object is the local actor itself
+		return models.NewActors(db).FindByURI(request.Object.URI)
+	}
+	attributedTo, ok := request.Object.Properties["attributedTo"].(string)
+	if !ok {
+		return nil, fmt.Errorf("object %s has no attributedTo", request.Object.URI)
+	}
+	return models.NewActors(db).FindByURI(attributedTo)
+}
+
+// isStatusObject reports whether the object is the Note, or Question, of a status.
+func isStatusObject(obj *models.Object) bool {
+	switch obj.Type {
+	case "Note", "Question":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,61 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/activitypub"
+	"github.com/davecheney/pub/internal/algorithms"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewPollVoteRequestProcessor sends the votes of local actors to the servers of remote polls.
+func NewPollVoteRequestProcessor(log *slog.Logger, db *gorm.DB, maxAge time.Duration) func(ctx context.Context) error {
+	log = log.With("worker", "PollVoteRequestProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := deliver(db, log, maxAge, pollVoteRequestScope, pollVoteRequestDomain, func(db *gorm.DB, request *models.StatusPollVoteRequest) error {
+				return processPollVoteRequest(log, db, request)
+			}); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(30 * time.Second):
+				// continue
+			}
+		}
+	}
+}
+
+func pollVoteRequestScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Actor").Preload("Actor.Object").
+		Preload("Status").Preload("Status.Object").Preload("Status.Actor").Preload("Status.Actor.Object")
+}
+
+func pollVoteRequestDomain(request *models.StatusPollVoteRequest) string {
+	return request.Status.Actor.Domain
+}
+
+func processPollVoteRequest(log *slog.Logger, db *gorm.DB, request *models.StatusPollVoteRequest) error {
+	log.Info("processPollVoteRequest", "request", request.ID, "actor", request.Actor.URI(), "poll", request.Status.URI())
+	account, err := models.NewAccounts(db).AccountForActor(request.Actor)
+	if err != nil {
+		return err
+	}
+	votes, err := models.NewPolls(db).Votes(&models.StatusPoll{StatusID: request.StatusID}, request.Actor)
+	if err != nil {
+		return err
+	}
+	options := algorithms.Map(votes, func(vote *models.StatusPollVote) *models.StatusPollOption {
+		return vote.Option
+	})
+	return activitypub.Vote(db.Statement.Context, account, request.Status, options)
+}
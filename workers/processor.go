@@ -1,8 +1,15 @@
 package workers
 
 import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/carlmjohnson/requests"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
 )
 
@@ -34,3 +41,110 @@ func forEach[T any](a []T, fn func(T) error) error {
 	}
 	return nil
 }
+
+const (
+	// minBackoff is the delay before the first retry of a failed request.
+	minBackoff = 30 * time.Second
+
+	// maxBackoff is the longest delay between retries of a failed request.
+	maxBackoff = 6 * time.Hour
+)
+
+// backoff returns the delay before the next attempt of a request which has
+// failed attempts times. The delay doubles with each attempt, from minBackoff
+// up to maxBackoff, plus up to 25% jitter so retries to a recovering peer are
+// spread out.
+func backoff(attempts uint32) time.Duration {
+	d := maxBackoff
+	if attempts > 0 && attempts < 16 {
+		d = min(minBackoff<<(attempts-1), maxBackoff)
+	}
+	return d + time.Duration(rand.Int63n(int64(d/4)+1))
+}
+
+// request is a record which embeds models.Request.
+type request interface {
+	GetRequest() *models.Request
+}
+
+// deliver makes one pass through the requests matching the scope which are due, calling fn for each one.
+// If fn returns nil, the request is deleted. If fn returns an error, the next attempt is scheduled
+// with backoff, unless the request is older than maxAge, or was refused by the remote server in a
+// way which will not change on retry, in which case it is abandoned.
+// domain returns the domain the request is delivered to, or "" if it is delivered to more than one.
+// Requests to a domain which has been paused are deferred until the pause expires, and
+// requests to a blocked domain are abandoned.
+func deliver[T request](db *gorm.DB, log *slog.Logger, maxAge time.Duration, scope func(*gorm.DB) *gorm.DB, domain func(T) string, fn func(*gorm.DB, T) error) error {
+	var pending []T
+	due := db.Scopes(scope).Where("next_attempt_at <= ?", time.Now())
+	return due.FindInBatches(&pending, 100, func(db *gorm.DB, batch int) error {
+		return forEach(pending, func(request T) error {
+			r := request.GetRequest()
+			peers := models.NewPeers(db)
+			dom := domain(request)
+			if dom != "" {
+				blocked, err := models.NewDomainBlocks(db).IsBlocked(dom)
+				if err != nil {
+					return err
+				}
+				if blocked {
+					log.Warn("abandoning request to blocked domain", "request", r.ID, "domain", dom)
+					return db.Delete(request).Error
+				}
+				until, paused, err := peers.PausedUntil(dom)
+				if err != nil {
+					return err
+				}
+				if paused {
+					return db.Model(request).Update("next_attempt_at", until).Error
+				}
+			}
+			start := time.Now()
+			err := fn(db, request)
+			if err == nil {
+				if dom != "" {
+					if err := peers.Succeeded(dom); err != nil {
+						return err
+					}
+				}
+				return db.Delete(request).Error
+			}
+			if dom != "" && isPeerFailure(err) {
+				if err := peers.Failed(dom); err != nil {
+					return err
+				}
+			}
+			if isPermanentFailure(err) {
+				log.Warn("abandoning refused request", "request", r.ID, "attempts", r.Attempts+1, "error", err)
+				return db.Delete(request).Error
+			}
+			if start.Sub(r.CreatedAt) > maxAge {
+				log.Warn("abandoning request", "request", r.ID, "created_at", r.CreatedAt, "attempts", r.Attempts+1, "error", err)
+				return db.Delete(request).Error
+			}
+			return db.Model(request).Updates(map[string]interface{}{
+				"attempts":        gorm.Expr("attempts + 1"),
+				"last_attempt":    start,
+				"last_result":     err.Error(),
+				"next_attempt_at": start.Add(backoff(r.Attempts + 1)),
+			}).Error
+		})
+	}).Error
+}
+
+// isPermanentFailure reports whether err indicates the remote server refused the
+// request, and would refuse it again.
+func isPermanentFailure(err error) bool {
+	return requests.HasStatusErr(err, http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusGone)
+}
+
+// isPeerFailure reports whether err indicates the remote server is unavailable,
+// rather than that it rejected the request.
+func isPeerFailure(err error) bool {
+	var re *requests.ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode >= 500
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
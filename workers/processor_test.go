@@ -0,0 +1,176 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/requests"
+	"github.com/davecheney/pub/models"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	require := require.New(t)
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Warn),
+	})
+	require.NoError(err)
+	require.NoError(db.AutoMigrate(models.AllTables()...))
+	return db
+}
+
+// statusError returns the error the client returns for a response with the status code.
+func statusError(code int) error {
+	req, _ := http.NewRequest("POST", "https://example.org/inbox", nil)
+	return fmt.Errorf("post: %w", (*requests.ResponseError)(&http.Response{StatusCode: code, Request: req}))
+}
+
+func TestBackoff(t *testing.T) {
+	require := require.New(t)
+
+	within := func(d, min time.Duration) {
+		require.GreaterOrEqual(d, min)
+		require.LessOrEqual(d, min+min/4)
+	}
+	within(backoff(1), minBackoff)
+	within(backoff(2), 2*minBackoff)
+	within(backoff(5), 16*minBackoff)
+	within(backoff(12), maxBackoff)
+	within(backoff(100), maxBackoff)
+}
+
+func TestIsPermanentFailure(t *testing.T) {
+	require := require.New(t)
+
+	for _, code := range []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusGone} {
+		require.True(isPermanentFailure(statusError(code)), code)
+	}
+	for _, code := range []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		require.False(isPermanentFailure(statusError(code)), code)
+	}
+	require.False(isPermanentFailure(&url.Error{Op: "Post", URL: "https://example.org/inbox", Err: errors.New("connection refused")}))
+}
+
+func TestDeliver(t *testing.T) {
+	db := setupTestDB(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	const maxAge = 48 * time.Hour
+
+	scope := func(db *gorm.DB) *gorm.DB { return db }
+	selected := 0
+	domain := func(*models.ActivitypubInboxRequest) string {
+		selected++
+		return "example.org"
+	}
+
+	// pass makes one pass through the due requests, returning the number of attempts.
+	pass := func(t *testing.T, tx *gorm.DB, err error) int {
+		attempts := 0
+		require.NoError(t, deliver(tx, log, maxAge, scope, domain, func(*gorm.DB, *models.ActivitypubInboxRequest) error {
+			attempts++
+			return err
+		}))
+		return attempts
+	}
+	enqueue := func(t *testing.T, tx *gorm.DB) *models.ActivitypubInboxRequest {
+		request := &models.ActivitypubInboxRequest{
+			ActorURI:   "https://example.org/users/bob",
+			ActivityID: "https://example.org/users/bob#1",
+			Activity:   map[string]any{},
+		}
+		require.NoError(t, tx.Create(request).Error)
+		return request
+	}
+	count := func(t *testing.T, tx *gorm.DB) int64 {
+		var n int64
+		require.NoError(t, tx.Model(&models.ActivitypubInboxRequest{}).Count(&n).Error)
+		return n
+	}
+
+	t.Run("delivered requests are deleted", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		enqueue(t, tx)
+		require.Equal(1, pass(t, tx, nil))
+		require.Zero(count(t, tx))
+	})
+
+	t.Run("failed requests are retried after a backoff", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		request := enqueue(t, tx)
+		require.Equal(1, pass(t, tx, statusError(http.StatusServiceUnavailable)))
+		require.NoError(tx.Take(request).Error)
+		require.EqualValues(1, request.Attempts)
+		require.True(request.NextAttemptAt.After(time.Now()))
+
+		// not due yet
+		require.Equal(0, pass(t, tx, nil))
+		require.EqualValues(1, count(t, tx))
+	})
+
+	t.Run("refused requests are abandoned", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		enqueue(t, tx)
+		require.Equal(1, pass(t, tx, statusError(http.StatusGone)))
+		require.Zero(count(t, tx))
+	})
+
+	t.Run("requests to blocked domains are abandoned", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		_, err := models.NewDomainBlocks(tx).Create("example.org", "spam")
+		require.NoError(err)
+
+		enqueue(t, tx)
+		require.Equal(0, pass(t, tx, nil))
+		require.Zero(count(t, tx))
+	})
+
+	t.Run("requests to paused domains are deferred", func(t *testing.T) {
+		require := require.New(t)
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		peers := models.NewPeers(tx)
+		for i := 0; i < 5; i++ {
+			require.NoError(peers.Failed("example.org"))
+		}
+		until, paused, err := peers.PausedUntil("example.org")
+		require.NoError(err)
+		require.True(paused)
+
+		request := enqueue(t, tx)
+		require.Equal(0, pass(t, tx, nil))
+		require.NoError(tx.Take(request).Error)
+		require.WithinDuration(until, request.NextAttemptAt, time.Second)
+		require.Zero(request.Attempts)
+
+		// the deferred request is not selected again until the pause expires
+		selected = 0
+		require.Equal(0, pass(t, tx, nil))
+		require.Zero(selected)
+	})
+}
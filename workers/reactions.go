@@ -7,18 +7,22 @@ import (
 
 	"github.com/davecheney/pub/activitypub"
 	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
 )
 
-// NewReactionRequestProcessor handles delivery of relationship requests.
-func NewReactionRequestProcessor(db *gorm.DB) func(ctx context.Context) error {
+// NewReactionRequestProcessor handles delivery of reaction requests.
+func NewReactionRequestProcessor(log *slog.Logger, db *gorm.DB, maxAge time.Duration) func(ctx context.Context) error {
+	log = log.With("worker", "ReactionRequestProcessor")
 	return func(ctx context.Context) error {
-		fmt.Println("ReactionRequestProcessor started")
-		defer fmt.Println("ReactionRequestProcessor stopped")
+		log.Info("started")
+		defer log.Info("stopped")
 
 		db := db.WithContext(ctx)
 		for {
-			if err := process(db, reactionRequestScope, processReactionRequest); err != nil {
+			if err := deliver(db, log, maxAge, reactionRequestScope, reactionRequestDomain, func(db *gorm.DB, request *models.ReactionRequest) error {
+				return processReactionRequest(log, db, request)
+			}); err != nil {
 				return err
 			}
 			select {
@@ -33,11 +37,16 @@ func NewReactionRequestProcessor(db *gorm.DB) func(ctx context.Context) error {
 
 func reactionRequestScope(db *gorm.DB) *gorm.DB {
 	return db.Preload("Actor").Preload("Actor.Object").
-		Preload("Target").Preload("Target.Object").Preload("Target.Actor").Preload("Target.Actor.Object").Where("attempts < 3")
+		Preload("Target").Preload("Target.Object").Preload("Target.Actor").Preload("Target.Actor.Object").
+		Preload("Recipient").Preload("Recipient.Object")
 }
 
-func processReactionRequest(db *gorm.DB, request *models.ReactionRequest) error {
-	fmt.Println("ReactionRequestProcessor: actor:", request.Actor.URI(), "target:", request.Target.URI(), "action:", request.Action)
+func reactionRequestDomain(request *models.ReactionRequest) string {
+	return request.Recipient.Domain
+}
+
+func processReactionRequest(log *slog.Logger, db *gorm.DB, request *models.ReactionRequest) error {
+	log.Info("processReactionRequest", "request", request.ID, "actor", request.Actor.URI(), "target", request.Target.URI(), "action", request.Action)
 
 	accounts := models.NewAccounts(db)
 	account, err := accounts.AccountForActor(request.Actor)
@@ -50,6 +59,14 @@ func processReactionRequest(db *gorm.DB, request *models.ReactionRequest) error
 		return activitypub.Like(db.Statement.Context, account, request.Target)
 	case "unlike":
 		return activitypub.Unlike(db.Statement.Context, account, request.Target)
+	case "reblog":
+		return activitypub.Announce(db.Statement.Context, account, request.Target, request.Recipient)
+	case "unreblog":
+		return activitypub.Unannounce(db.Statement.Context, account, request.Target, request.Recipient)
+	case "pin":
+		return activitypub.Add(db.Statement.Context, account, request.Target, request.Recipient)
+	case "unpin":
+		return activitypub.Remove(db.Statement.Context, account, request.Target, request.Recipient)
 	default:
 		return fmt.Errorf("unknown action %q", request.Action)
 	}
@@ -12,7 +12,7 @@ import (
 )
 
 // RelationshipRequestProcessor handles delivery of relationship requests.
-func NewRelationshipRequestProcessor(log *slog.Logger, db *gorm.DB) func(ctx context.Context) error {
+func NewRelationshipRequestProcessor(log *slog.Logger, db *gorm.DB, maxAge time.Duration) func(ctx context.Context) error {
 	log = log.With("worker", "RelationshipRequestProcessor")
 	return func(ctx context.Context) error {
 		log.Info("started")
@@ -20,7 +20,7 @@ func NewRelationshipRequestProcessor(log *slog.Logger, db *gorm.DB) func(ctx con
 
 		db := db.WithContext(ctx)
 		for {
-			if err := process(db, relationshipRequestScope, func(db *gorm.DB, request *models.RelationshipRequest) error {
+			if err := deliver(db, log, maxAge, relationshipRequestScope, relationshipRequestDomain, func(db *gorm.DB, request *models.RelationshipRequest) error {
 				return processRelationshipRequest(log, db, request)
 			}); err != nil {
 				return err
@@ -37,7 +37,11 @@ func NewRelationshipRequestProcessor(log *slog.Logger, db *gorm.DB) func(ctx con
 
 func relationshipRequestScope(db *gorm.DB) *gorm.DB {
 	return db.Preload("Actor").Preload("Actor.Object").
-		Preload("Target").Preload("Target.Object").Where("attempts < 3")
+		Preload("Target").Preload("Target.Object")
+}
+
+func relationshipRequestDomain(request *models.RelationshipRequest) string {
+	return request.Target.Domain
 }
 
 func processRelationshipRequest(log *slog.Logger, db *gorm.DB, request *models.RelationshipRequest) error {
@@ -49,10 +53,43 @@ func processRelationshipRequest(log *slog.Logger, db *gorm.DB, request *models.R
 	}
 	switch request.Action {
 	case "follow":
-		return activitypub.Follow(db.Statement.Context, account, request.Target)
+		followURI, err := followURI(db, request.Actor, request.Target)
+		if err != nil {
+			return err
+		}
+		return activitypub.Follow(db.Statement.Context, account, request.Target, followURI)
 	case "unfollow":
-		return activitypub.Unfollow(db.Statement.Context, account, request.Target)
+		followURI, err := followURI(db, request.Actor, request.Target)
+		if err != nil {
+			return err
+		}
+		return activitypub.Unfollow(db.Statement.Context, account, request.Target, followURI)
+	case "block":
+		return activitypub.Block(db.Statement.Context, account, request.Target)
+	case "unblock":
+		return activitypub.Unblock(db.Statement.Context, account, request.Target)
+	case "accept":
+		followURI, err := followURI(db, request.Target, request.Actor)
+		if err != nil {
+			return err
+		}
+		return activitypub.Accept(db.Statement.Context, account, request.Target, followURI)
+	case "reject":
+		followURI, err := followURI(db, request.Target, request.Actor)
+		if err != nil {
+			return err
+		}
+		return activitypub.Reject(db.Statement.Context, account, request.Target, followURI)
 	default:
 		return fmt.Errorf("unknown action %q", request.Action)
 	}
 }
+
+// followURI returns the id of the follower's Follow of the actor, if it is known.
+func followURI(db *gorm.DB, follower, actor *models.Actor) (string, error) {
+	var follow models.Relationship
+	if err := db.Take(&follow, "actor_id = ? and target_id = ?", follower.ObjectID, actor.ObjectID).Error; err != nil {
+		return "", err
+	}
+	return follow.FollowURI, nil
+}
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/activitypub"
+	ap "github.com/davecheney/pub/internal/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewStatusRepliesProcessor fetches the replies to remote statuses whose threads have been
+// opened, to at most depth replies below the status and at most limit replies in total.
+func NewStatusRepliesProcessor(log *slog.Logger, db *gorm.DB, client *ap.Client, maxAge time.Duration, depth, limit int) func(ctx context.Context) error {
+	log = log.With("worker", "StatusRepliesProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := deliver(db, log, maxAge, statusRepliesRequestScope, statusRepliesRequestDomain, func(db *gorm.DB, request *models.StatusRepliesRequest) error {
+				log.Info("processStatusRepliesRequest", "request", request.ID, "status", request.Status.URI())
+				return activitypub.FetchReplies(ctx, log.With("request", request.ID), db, client, request.Status, depth, limit)
+			}); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(5 * time.Second):
+				// continue
+			}
+		}
+	}
+}
+
+func statusRepliesRequestScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Status").Preload("Status.Actor").Preload("Status.Object")
+}
+
+func statusRepliesRequestDomain(request *models.StatusRepliesRequest) string {
+	return request.Status.Actor.Domain
+}
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/activitypub"
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewReportRequestProcessor forwards reports to the reported actor's server.
+func NewReportRequestProcessor(log *slog.Logger, db *gorm.DB, maxAge time.Duration) func(ctx context.Context) error {
+	log = log.With("worker", "ReportRequestProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := deliver(db, log, maxAge, reportRequestScope, reportRequestDomain, func(db *gorm.DB, request *models.ReportRequest) error {
+				return processReportRequest(log, db, request)
+			}); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(30 * time.Second):
+				// continue
+			}
+		}
+	}
+}
+
+func reportRequestScope(db *gorm.DB) *gorm.DB {
+	return db.Preload("Report").Preload("Report.Actor").Preload("Report.Actor.Object").
+		Preload("Report.Target").Preload("Report.Target.Object").
+		Preload("Report.Statuses").Preload("Report.Statuses.Status").Preload("Report.Statuses.Status.Object")
+}
+
+func reportRequestDomain(request *models.ReportRequest) string {
+	return request.Report.Target.Domain
+}
+
+func processReportRequest(log *slog.Logger, db *gorm.DB, request *models.ReportRequest) error {
+	log.Info("processReportRequest", "request", request.ID, "report", request.Report.ID, "target", request.Report.Target.URI())
+	// the report is sent by the instance's admin account so the reporter is not revealed.
+	instance, err := models.NewInstances(db).FindByDomain(request.Report.Actor.Domain)
+	if err != nil {
+		return err
+	}
+	return activitypub.Flag(db.Statement.Context, instance.Admin, request.Report)
+}
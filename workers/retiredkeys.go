@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewRetiredKeyExpiryProcessor stops publishing the keys local accounts have
+// rotated away from once their grace period has passed.
+func NewRetiredKeyExpiryProcessor(log *slog.Logger, db *gorm.DB) func(ctx context.Context) error {
+	log = log.With("worker", "RetiredKeyExpiryProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := models.NewAccounts(db).ExpireRetiredKeys(time.Now()); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Hour):
+				// continue
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/davecheney/pub/models"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// NewSeenActivityExpiryProcessor removes records of activities delivered to the
+// inbox once they are older than the ttl.
+func NewSeenActivityExpiryProcessor(log *slog.Logger, db *gorm.DB, ttl time.Duration) func(ctx context.Context) error {
+	log = log.With("worker", "SeenActivityExpiryProcessor")
+	return func(ctx context.Context) error {
+		log.Info("started")
+		defer log.Info("stopped")
+
+		db := db.WithContext(ctx)
+		for {
+			if err := models.NewSeenActivities(db).Expire(time.Now().Add(-ttl)); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Hour):
+				// continue
+			}
+		}
+	}
+}